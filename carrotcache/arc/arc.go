@@ -0,0 +1,277 @@
+package arc
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// ARC（Adaptive Replacement Cache）淘汰策略，参见 Megiddo & Modha, "ARC: A Self-Tuning, Low Overhead Replacement Cache"
+// 缓存的数据分布在两个 LRU 链表中：T1 保存只被访问过一次的数据（体现最近性），T2 保存至少被访问过两次的数据（体现频率）。
+// 从 T1、T2 淘汰的数据只保留 key，分别记录在幽灵链表 B1、B2 中。
+// 命中 B1 说明 T1 太小，命中 B2 说明 T2 太小，据此自适应地调整 T1 的目标大小 p，无需人工调参。
+// 与 lru.Cache 一样按照 len(key)+value.Len() 及每条数据的额外开销统计内存，幽灵 key 仍按被淘汰时的大小参与 p 的调整，
+// 同时按照 key 及节点实际占用的内存 len(key)+ghostOverhead 计入 Bytes，与缓存数据共享 maxData。
+// 幽灵 key 超过 maxData 的 ghostPercent% 时优先裁剪幽灵 key，否则淘汰缓存数据，使两者之和不超过 maxData。
+
+// ghostPercent：超出内存限制时，幽灵 key 占用的内存超过 maxData 的该百分比则先裁剪幽灵 key，保留一部分历史用于调整 p
+const ghostPercent = 25
+
+// 数据所在的链表
+const (
+	t1 = iota
+	t2
+	b1
+	b2
+)
+
+// Cache：ARC 缓存，不是并发安全的
+type Cache struct {
+	maxData   int64                    // 允许使用最大内存，为 0 表示不限制
+	p         int64                    // T1 的目标内存大小，在 0 到 maxData 之间自适应调整
+	sizes     [4]int64                 // T1、T2、B1、B2 各自的内存大小，B1、B2 按幽灵 key 被淘汰时的大小计算，用于调整 p
+	ghostData int64                    // B1、B2 中的幽灵 key 实际占用的内存，包括每个 key 的额外开销 ghostOverhead
+	lists     [4]*list.List            // 依次为 T1、T2、B1、B2，front 为最近访问
+	cache     map[string]*list.Element // 包括幽灵 key
+	OnEvicted func(key string, value lru.Value)
+}
+
+// entry：链表节点的数据类型，幽灵节点的 value 为 nil
+type entry struct {
+	lru.Entry
+	size int64 // 所占用的内存，包括额外开销，变为幽灵后保持不变
+	list int   // 所在的链表
+}
+
+// entryOverhead：每条数据的额外开销，包括链表节点、entry 结构体及 map。幽灵节点释放了 value，但仍按原大小参与 p 的调整
+const entryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(entry{})) + lru.MapEntryOverhead
+
+// ghostOverhead：幽灵 key 的额外开销，包括链表节点、entry 结构体及 map，不再包括 value
+const ghostOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(entry{})) + lru.MapEntryOverhead
+
+// New：实例化 ARC 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	c := &Cache{
+		maxData:   maxData,
+		cache:     make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	return c
+}
+
+// Get：根据 key 查找 value，命中的数据移动到 T2 的最前面
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*entry)
+	if e.list == b1 || e.list == b2 {
+		return nil, false
+	}
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	c.move(ele, t2)
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*entry); e.list <= t2 && !e.Expired(time.Now()) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	size := int64(len(key)) + int64(value.Len()) + entryOverhead
+	ele, ok := c.cache[key]
+	if !ok {
+		// 全新的数据进入 T1
+		c.cache[key] = c.lists[t1].PushFront(&entry{Entry: lru.Entry{Key: key, Value: value, Expire: expire}, size: size, list: t1})
+		c.sizes[t1] += size
+		c.evict(false)
+		return
+	}
+	e := ele.Value.(*entry)
+	inB2 := false
+	switch e.list {
+	case b1:
+		// 命中 B1：增大 T1 的目标大小，B2 相对越大，调整幅度越大
+		delta := e.size
+		if c.sizes[b1] > 0 && c.sizes[b2] > c.sizes[b1] {
+			delta = e.size * c.sizes[b2] / c.sizes[b1]
+		}
+		c.p = min(c.p+delta, c.maxData)
+	case b2:
+		// 命中 B2：减小 T1 的目标大小
+		delta := e.size
+		if c.sizes[b2] > 0 && c.sizes[b1] > c.sizes[b2] {
+			delta = e.size * c.sizes[b1] / c.sizes[b2]
+		}
+		c.p = max(c.p-delta, 0)
+		inB2 = true
+	}
+	// 更新数据并移动到 T2 的最前面，幽灵 key 由此重新成为缓存数据
+	c.sizes[e.list] -= e.size
+	e.Value, e.Expire, e.size = value, expire, size
+	c.sizes[e.list] += e.size
+	c.move(ele, t2)
+	c.evict(inB2)
+}
+
+// RemoveOldest：按照 ARC 的替换规则淘汰一条数据，其 key 记录到对应的幽灵链表
+func (c *Cache) RemoveOldest() {
+	c.replace(false)
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：移除 T1、T2 中所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	return lru.RemoveExpiredElements(c.removeElement, c.lists[:b1]...)
+}
+
+// Len：获取 Cache 添加了多少条数据，不包括幽灵 key
+func (c *Cache) Len() int {
+	return c.lists[t1].Len() + c.lists[t2].Len()
+}
+
+// Bytes：获取 Cache 当前已使用的内存，包括幽灵 key 实际占用的内存
+func (c *Cache) Bytes() int64 {
+	return c.sizes[t1] + c.sizes[t2] + c.ghostData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，T1 的目标大小不超过新的上限，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	c.p = min(c.p, maxData)
+	c.evict(false)
+}
+
+// evict：缓存数据与幽灵 key 超出限制时不断淘汰，幽灵 key 过多时先裁剪幽灵 key，否则淘汰缓存数据；
+// 随后按照 ARC 的规则裁剪幽灵链表，使 T1+B1 不超过 maxData，四个链表之和不超过 2*maxData
+func (c *Cache) evict(inB2 bool) {
+	if c.maxData == 0 {
+		return
+	}
+	for c.Bytes() > c.maxData {
+		if c.ghostData > c.maxData*ghostPercent/100 || c.Len() == 0 {
+			c.trimGhost()
+		} else {
+			c.replace(inB2)
+		}
+	}
+	for c.lists[b1].Len() > 0 && c.sizes[t1]+c.sizes[b1] > c.maxData {
+		c.removeElement(c.lists[b1].Back())
+	}
+	for c.lists[b2].Len() > 0 && c.sizes[t1]+c.sizes[t2]+c.sizes[b1]+c.sizes[b2] > 2*c.maxData {
+		c.removeElement(c.lists[b2].Back())
+	}
+}
+
+// trimGhost：移除 B1、B2 中较长的一个链表里最旧的幽灵 key
+func (c *Cache) trimGhost() {
+	if c.lists[b1].Len() > 0 && c.lists[b1].Len() >= c.lists[b2].Len() {
+		c.removeElement(c.lists[b1].Back())
+	} else if c.lists[b2].Len() > 0 {
+		c.removeElement(c.lists[b2].Back())
+	}
+}
+
+// replace：T1 超过目标大小 p 时淘汰 T1 中最久未访问的数据到 B1，否则淘汰 T2 中最久未访问的数据到 B2
+func (c *Cache) replace(inB2 bool) {
+	t1Len, t2Len := c.lists[t1].Len(), c.lists[t2].Len()
+	if t1Len == 0 && t2Len == 0 {
+		return
+	}
+	if t1Len > 0 && (t2Len == 0 || c.sizes[t1] > c.p || (inB2 && c.sizes[t1] == c.p)) {
+		c.demote(c.lists[t1].Back(), b1)
+	} else {
+		c.demote(c.lists[t2].Back(), b2)
+	}
+}
+
+// demote：将缓存数据变为幽灵 key，调用回调函数并释放 value。不限制内存时幽灵链表无法裁剪，直接移除
+func (c *Cache) demote(ele *list.Element, ghost int) {
+	if c.maxData == 0 {
+		c.removeElement(ele)
+		return
+	}
+	e := ele.Value.(*entry)
+	value := e.Value
+	e.Value = nil
+	c.move(ele, ghost)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, value)
+	}
+}
+
+// move：将节点移动到 to 链表的最前面
+func (c *Cache) move(ele *list.Element, to int) {
+	e := ele.Value.(*entry)
+	if e.list == to {
+		c.lists[to].MoveToFront(ele)
+		return
+	}
+	c.lists[e.list].Remove(ele)
+	c.sizes[e.list] -= e.size
+	if e.list >= b1 {
+		c.ghostData -= int64(len(e.Key)) + ghostOverhead
+	}
+	e.list = to
+	c.cache[e.Key] = c.lists[to].PushFront(e)
+	c.sizes[to] += e.size
+	if to >= b1 {
+		c.ghostData += int64(len(e.Key)) + ghostOverhead
+	}
+}
+
+// removeElement：彻底移除节点并维护映射关系与内存值，移除缓存数据时调用回调函数
+func (c *Cache) removeElement(ele *list.Element) {
+	e := ele.Value.(*entry)
+	c.lists[e.list].Remove(ele)
+	delete(c.cache, e.Key)
+	c.sizes[e.list] -= e.size
+	if e.list >= b1 {
+		c.ghostData -= int64(len(e.Key)) + ghostOverhead
+	}
+	if e.list <= t2 && c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package arc
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_Get：测试添加与获取，再次访问的数据进入 T2
+func TestCache_Get(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("123123123"))
+	if e := c.cache["key1"].Value.(*entry); e.list != t1 {
+		t.Fatalf("new key1 should be in T1")
+	}
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "123123123" {
+		t.Fatalf("cache hit key1 = 123123123 failed")
+	}
+	if e := c.cache["key1"].Value.(*entry); e.list != t2 {
+		t.Fatalf("key1 should be moved to T2")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+// TestCache_Adapt：测试命中幽灵链表时自适应调整 p
+func TestCache_Adapt(t *testing.T) {
+	var evicted []string
+	// 可以容纳两条数据及一个幽灵 key
+	c := New(int64(len("key1v1key2v2key1"))+2*entryOverhead+ghostOverhead, func(key string, value lru.Value) {
+		evicted = append(evicted, key)
+	})
+	c.Add("key1", String("v1"))
+	c.Add("key2", String("v2"))
+	c.Get("key2")
+	c.Add("key3", String("v3")) // T1 超过目标大小，key1 被淘汰到 B1
+	if len(evicted) != 1 || evicted[0] != "key1" {
+		t.Fatalf("got evicted %v, want [key1]", evicted)
+	}
+	if _, ok := c.Get("key1"); ok || c.Len() != 2 {
+		t.Fatalf("key1 should be a ghost")
+	}
+	c.Add("key1", String("v1")) // 命中 B1，T1 的目标大小增大
+	if c.p == 0 {
+		t.Fatalf("p should grow after a B1 hit")
+	}
+	if e := c.cache["key1"].Value.(*entry); e.list != t2 {
+		t.Fatalf("key1 should be moved to T2")
+	}
+	if c.Bytes() > c.maxData {
+		t.Fatalf("bytes %d exceed limit %d", c.Bytes(), c.maxData)
+	}
+}
+
+// TestCache_ScanResistance：测试一次性扫描无法挤出 T2 中被反复访问的数据
+func TestCache_ScanResistance(t *testing.T) {
+	c := New(100*(10+entryOverhead), nil)
+	// 每条数据占用 10 字节及额外开销，缓存最多容纳 100 条
+	hot := make([]string, 50)
+	for i := range hot {
+		hot[i] = "hot" + strconv.Itoa(100 + i)[1:] + "xx"
+		c.Add(hot[i], String("vvv"))
+		c.Get(hot[i])
+	}
+	for i := 0; i < 1000; i++ {
+		key := "scan" + strconv.Itoa(1000 + i)[1:]
+		if _, ok := c.Get(key); !ok {
+			c.Add(key, String("v"))
+		}
+		// 幽灵 key 同样计入内存
+		if c.Bytes() > c.maxData {
+			t.Fatalf("bytes %d (ghosts %d) exceed limit %d", c.Bytes(), c.ghostData, c.maxData)
+		}
+	}
+	if c.ghostData == 0 {
+		t.Fatalf("scanned keys should leave ghosts")
+	}
+	for _, key := range hot {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("hot key %s should survive the scan", key)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package arena
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// 对 GC 友好的存储，参考 BigCache/FreeCache 的设计。
+// 所有数据的 key、版本号与 value 依次追加写入一个大的环形字节数组（slab）中，索引为 map[uint64]uint32，即 key 的哈希到数据在 slab 中的偏移量。
+// slab 与索引都不包含指针，GC 无需逐条扫描，因此 GC 的开销与数据条数无关。
+// 查找时会比较 slab 中保存的 key，哈希冲突的 key 保存在以 key 为索引的 overflow 中，不会互相覆盖，冲突极少，overflow 通常为空。
+// 淘汰按照写入顺序进行（FIFO），删除及修改只是将旧数据标记为已删除，其空间在淘汰到该位置或 slab 扩容时回收。
+// 偏移量为 uint32，因此单个 Cache 最多使用 4GB（32 位平台上为 2GB），更大的缓存可以配合 concurrentcache.Cache 的分片使用。
+
+const (
+	headerSize  = 33      // 数据头：总长度(4) + 过期时间(8) + 软过期时间(8) + key 的哈希(8) + key 的长度(2) + 版本号的长度(2) + 删除标记(1)
+	indexSize   = 17      // 索引中每条数据的开销：哈希(8) + 偏移量(4) + tophash(1)，并按照平均装载因子约 6/8 折算
+	initialSize = 1 << 16 // slab 的初始大小，写满后翻倍扩容，直到达到内存上限
+	maxKeyLen   = math.MaxUint16
+	maxSlabSize = math.MaxUint32
+)
+
+// Cache：基于环形 slab 的缓存，value 必须是 byteview.ByteView，B、SoftExpire 与 Version 均会被保存，不是并发安全的
+type Cache struct {
+	maxData   int64             // 允许使用最大内存，为 0 表示不限制
+	maxSlab   int               // slab 允许的最大长度
+	nowData   int64             // 当前有效数据所使用的内存，包括数据头及索引的开销
+	slab      []byte            // 环形字节数组
+	head      int               // 最早写入的数据的偏移量
+	tail      int               // 下一条数据写入的偏移量
+	margin    int               // 回绕时 slab 末尾有效数据的结束位置
+	wrapped   bool              // 是否已经回绕，即有效数据分布在 [head, margin) 与 [0, tail) 两段
+	count     int               // slab 中的数据条数，包括已删除的数据
+	index     map[uint64]uint32 // key 的哈希到偏移量的映射，只包含有效数据
+	overflow  map[string]uint32 // 与 index 中的数据哈希冲突的 key 到偏移量的映射，懒加载
+	hash      func(string) uint64
+	OnEvicted func(key string, value lru.Value)
+}
+
+// New：实例化缓存，maxData 为 0 或超过 4GB 时按 4GB 限制，32 位平台上不超过 int 的上限
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	maxSlab := slabLimit(maxData)
+	size := initialSize
+	if size > maxSlab {
+		size = maxSlab
+	}
+	return &Cache{
+		maxData:   maxData,
+		maxSlab:   maxSlab,
+		slab:      make([]byte, size),
+		index:     make(map[uint64]uint32),
+		hash:      fnvhash.Sum64,
+		OnEvicted: onEvicted,
+	}
+}
+
+// slabLimit：根据 maxData 计算 slab 允许的最大长度，maxData 为 0 或超过 4GB 时按 4GB 限制，32 位平台上不超过 int 的上限
+func slabLimit(maxData int64) int {
+	maxSlab := int64(maxSlabSize)
+	if maxSlab > math.MaxInt {
+		maxSlab = math.MaxInt
+	}
+	if maxData > 0 && maxData < maxSlab {
+		maxSlab = maxData
+	}
+	return int(maxSlab)
+}
+
+// Get：根据 key 查找 value，返回的 ByteView 是 slab 中数据的拷贝
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	off, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	// 已经过期的数据视为未命中，顺便将其惰性删除
+	if c.expired(off, time.Now()) {
+		c.remove(off)
+		return nil, false
+	}
+	return c.value(off), true
+}
+
+// Peek：与 Get 相同，但不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	off, ok := c.lookup(key)
+	if !ok || c.expired(off, time.Now()) {
+		return nil, false
+	}
+	return c.value(off), true
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期。
+// 修改已有数据时将旧数据标记为已删除并在末尾写入新数据；value 不是 ByteView 或超过内存上限时无法保存，直接视为被淘汰
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if off, ok := c.lookup(key); ok {
+		c.markDeleted(off)
+	}
+	view, ok := value.(byteview.ByteView)
+	b := view.B
+	n := headerSize + len(key) + len(view.Version) + len(b)
+	if !ok || len(key) > maxKeyLen || len(view.Version) > maxKeyLen || n > c.maxSlab {
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, value)
+		}
+		return
+	}
+	off, ok := c.alloc(n)
+	for !ok {
+		// 空间不足时优先扩容，已经达到上限则淘汰最早写入的数据
+		if len(c.slab) < c.maxSlab {
+			c.grow()
+		} else {
+			c.pop()
+		}
+		off, ok = c.alloc(n)
+	}
+	hash := c.hash(key)
+	var exp, soft int64
+	if !expire.IsZero() {
+		exp = expire.UnixNano()
+	}
+	if !view.SoftExpire.IsZero() {
+		soft = view.SoftExpire.UnixNano()
+	}
+	entry := c.slab[off : off+n]
+	binary.LittleEndian.PutUint32(entry[0:], uint32(n))
+	binary.LittleEndian.PutUint64(entry[4:], uint64(exp))
+	binary.LittleEndian.PutUint64(entry[12:], uint64(soft))
+	binary.LittleEndian.PutUint64(entry[20:], hash)
+	binary.LittleEndian.PutUint16(entry[28:], uint16(len(key)))
+	binary.LittleEndian.PutUint16(entry[30:], uint16(len(view.Version)))
+	entry[32] = 0
+	copy(entry[headerSize:], key)
+	copy(entry[headerSize+len(key):], view.Version)
+	copy(entry[headerSize+len(key)+len(view.Version):], b)
+	c.setIndex(hash, key, off)
+	c.nowData += int64(n + indexSize)
+	// slab 不会超过 maxData，但索引的开销在 slab 之外，仍可能超出限制
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest：淘汰最早写入的一条有效数据
+func (c *Cache) RemoveOldest() {
+	for c.count > 0 {
+		if c.pop() {
+			return
+		}
+	}
+}
+
+// Remove：根据 key 移除对应的数据，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if off, ok := c.lookup(key); ok {
+		c.remove(off)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的数据，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	c.each(func(off int) {
+		if !c.deleted(off) && c.expired(off, now) {
+			c.remove(off)
+			n++
+		}
+	})
+	return n
+}
+
+// Len：获取 Cache 添加了多少条有效数据
+func (c *Cache) Len() int {
+	return len(c.index) + len(c.overflow)
+}
+
+// Bytes：获取 Cache 当前有效数据所使用的内存，包括数据头及索引的开销
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，slab 的长度上限随之调整，已分配的 slab 不会缩小，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	c.maxSlab = slabLimit(maxData)
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// lookup：根据 key 查找数据的偏移量，会校验 key 以排除哈希冲突，与其他 key 冲突的数据在 overflow 中查找
+func (c *Cache) lookup(key string) (int, bool) {
+	if off, ok := c.index[c.hash(key)]; ok && c.keyEquals(int(off), key) {
+		return int(off), true
+	}
+	if off, ok := c.overflow[key]; ok {
+		return int(off), true
+	}
+	return 0, false
+}
+
+// setIndex：记录 key 的偏移量，哈希已经被其他 key 占用时记录到 overflow 中
+func (c *Cache) setIndex(hash uint64, key string, off int) {
+	if prev, ok := c.index[hash]; !ok || c.keyEquals(int(prev), key) {
+		c.index[hash] = uint32(off)
+		return
+	}
+	if c.overflow == nil {
+		c.overflow = make(map[string]uint32)
+	}
+	c.overflow[key] = uint32(off)
+}
+
+// unsetIndex：删除 off 处数据的索引
+func (c *Cache) unsetIndex(off int) {
+	hash := binary.LittleEndian.Uint64(c.slab[off+20:])
+	if prev, ok := c.index[hash]; ok && int(prev) == off {
+		delete(c.index, hash)
+		return
+	}
+	delete(c.overflow, c.key(off))
+}
+
+// alloc：在 slab 中分配 n 字节，空间不足时返回 false
+func (c *Cache) alloc(n int) (int, bool) {
+	if c.count == 0 {
+		c.head, c.tail, c.margin, c.wrapped = 0, 0, 0, false
+	}
+	if !c.wrapped {
+		if c.tail+n <= len(c.slab) {
+			off := c.tail
+			c.tail += n
+			c.count++
+			return off, true
+		}
+		// 末尾空间不足时回绕到开头
+		if n <= c.head {
+			c.margin, c.wrapped = c.tail, true
+			c.tail = n
+			c.count++
+			return 0, true
+		}
+		return 0, false
+	}
+	if c.tail+n <= c.head {
+		off := c.tail
+		c.tail += n
+		c.count++
+		return off, true
+	}
+	return 0, false
+}
+
+// pop：释放最早写入的数据所占用的空间，该数据有效时将其淘汰并返回 true
+func (c *Cache) pop() bool {
+	if c.count == 0 {
+		return false
+	}
+	off := c.head
+	evicted := !c.deleted(off)
+	if evicted {
+		c.remove(off)
+	}
+	c.head += c.size(off)
+	c.count--
+	if c.wrapped && c.head == c.margin {
+		c.head, c.wrapped = 0, false
+	}
+	return evicted
+}
+
+// grow：将 slab 扩容一倍，并丢弃已删除的数据以整理空间
+func (c *Cache) grow() {
+	size := len(c.slab) * 2
+	if size > c.maxSlab || size <= 0 {
+		size = c.maxSlab
+	}
+	slab := make([]byte, size)
+	tail, count := 0, 0
+	c.each(func(off int) {
+		if c.deleted(off) {
+			return
+		}
+		n := c.size(off)
+		copy(slab[tail:], c.slab[off:off+n])
+		c.reindex(off, tail)
+		tail += n
+		count++
+	})
+	c.slab, c.head, c.tail, c.margin, c.wrapped, c.count = slab, 0, tail, 0, false, count
+}
+
+// reindex：将 off 处的有效数据的索引改为 to。扩容过程中 index 里已有新旧两种偏移量，无法通过比较偏移量区分，
+// 因此按照 key 是否在 overflow 中判断，overflow 为空时无需读取 key
+func (c *Cache) reindex(off, to int) {
+	if len(c.overflow) > 0 {
+		key := c.key(off)
+		if _, ok := c.overflow[key]; ok {
+			c.overflow[key] = uint32(to)
+			return
+		}
+	}
+	c.index[binary.LittleEndian.Uint64(c.slab[off+20:])] = uint32(to)
+}
+
+// each：按照写入顺序遍历 slab 中的所有数据，包括已删除的数据
+func (c *Cache) each(f func(off int)) {
+	off, wrapped, margin := c.head, c.wrapped, c.margin
+	for i, n := 0, c.count; i < n; i++ {
+		next := off + c.size(off)
+		f(off)
+		off = next
+		if wrapped && off == margin {
+			off, wrapped = 0, false
+		}
+	}
+}
+
+// remove：删除有效数据并调用回调函数
+func (c *Cache) remove(off int) {
+	var value lru.Value
+	if c.OnEvicted != nil {
+		value = c.value(off)
+	}
+	key := c.key(off)
+	c.markDeleted(off)
+	if c.OnEvicted != nil {
+		c.OnEvicted(key, value)
+	}
+}
+
+// markDeleted：将数据标记为已删除，并维护索引与内存值
+func (c *Cache) markDeleted(off int) {
+	c.slab[off+32] = 1
+	c.unsetIndex(off)
+	c.nowData -= int64(c.size(off) + indexSize)
+}
+
+// size：数据的总长度，包括数据头
+func (c *Cache) size(off int) int {
+	return int(binary.LittleEndian.Uint32(c.slab[off:]))
+}
+
+// deleted：数据是否已被删除
+func (c *Cache) deleted(off int) bool {
+	return c.slab[off+32] == 1
+}
+
+// expired：判断数据在 now 时刻是否已经过期
+func (c *Cache) expired(off int, now time.Time) bool {
+	exp := int64(binary.LittleEndian.Uint64(c.slab[off+4:]))
+	return exp != 0 && now.UnixNano() > exp
+}
+
+// key：数据的 key
+func (c *Cache) key(off int) string {
+	n := int(binary.LittleEndian.Uint16(c.slab[off+28:]))
+	return string(c.slab[off+headerSize : off+headerSize+n])
+}
+
+// keyEquals：判断数据的 key 是否等于 key，比较时不会产生内存分配
+func (c *Cache) keyEquals(off int, key string) bool {
+	n := int(binary.LittleEndian.Uint16(c.slab[off+28:]))
+	return n == len(key) && string(c.slab[off+headerSize:off+headerSize+n]) == key
+}
+
+// value：数据的 value 的拷贝
+func (c *Cache) value(off int) byteview.ByteView {
+	start := off + headerSize + int(binary.LittleEndian.Uint16(c.slab[off+28:]))
+	end := start + int(binary.LittleEndian.Uint16(c.slab[off+30:]))
+	v := byteview.ByteView{
+		B:       byteview.CloneBytes(c.slab[end : off+c.size(off)]),
+		Version: string(c.slab[start:end]),
+	}
+	if exp := int64(binary.LittleEndian.Uint64(c.slab[off+4:])); exp != 0 {
+		v.Expire = time.Unix(0, exp)
+	}
+	if soft := int64(binary.LittleEndian.Uint64(c.slab[off+12:])); soft != 0 {
+		v.SoftExpire = time.Unix(0, soft)
+	}
+	return v
+}
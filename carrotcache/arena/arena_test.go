@@ -0,0 +1,133 @@
+package arena
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+func bv(s string) byteview.ByteView {
+	return byteview.ByteView{B: []byte(s)}
+}
+
+// TestCache_Get：测试添加、获取与修改，返回的数据是拷贝
+func TestCache_Get(t *testing.T) {
+	c := New(int64(0), nil)
+	value := bv("123123123")
+	c.Add("key1", value)
+	value.B[0] = 'x'
+	v, ok := c.Get("key1")
+	if !ok || v.(byteview.ByteView).String() != "123123123" {
+		t.Fatalf("cache hit key1 = 123123123 failed")
+	}
+	v.(byteview.ByteView).B[0] = 'x'
+	if v, _ := c.Get("key1"); v.(byteview.ByteView).String() != "123123123" {
+		t.Fatalf("cached value should not be modified")
+	}
+	c.Add("key1", bv("1"))
+	if v, ok := c.Get("key1"); !ok || v.(byteview.ByteView).String() != "1" || c.Len() != 1 {
+		t.Fatalf("update key1 failed")
+	}
+	if c.count != 2 {
+		t.Fatalf("old entry should be kept until reclaimed, got count %d", c.count)
+	}
+	soft := time.Unix(0, time.Now().Add(time.Minute).UnixNano())
+	c.Add("key2", byteview.ByteView{B: []byte("2"), SoftExpire: soft, Version: "v1"})
+	if v, _ := c.Get("key2"); !v.(byteview.ByteView).SoftExpire.Equal(soft) || v.(byteview.ByteView).Version != "v1" ||
+		v.(byteview.ByteView).String() != "2" {
+		t.Fatalf("soft expire and version should be kept")
+	}
+}
+
+// TestCache_Wrap：测试 slab 写满后回绕，按照写入顺序淘汰
+func TestCache_Wrap(t *testing.T) {
+	const entrySize = headerSize + indexSize + len("key000") + len("value")
+	var evicted []string
+	c := New(int64(entrySize*10), func(key string, value lru.Value) {
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 25; i++ {
+		c.Add(fmt.Sprintf("key%03d", i), bv("value"))
+	}
+	if c.Len() != 10 || len(evicted) != 15 || evicted[0] != "key000" || evicted[14] != "key014" {
+		t.Fatalf("got len %d, evicted %v", c.Len(), evicted)
+	}
+	for i := 15; i < 25; i++ {
+		if v, ok := c.Get(fmt.Sprintf("key%03d", i)); !ok || v.(byteview.ByteView).String() != "value" {
+			t.Fatalf("key%03d should be cached", i)
+		}
+	}
+}
+
+// TestCache_Grow：测试不限制内存时 slab 自动扩容，并在扩容时丢弃已删除的数据
+func TestCache_Grow(t *testing.T) {
+	c := New(int64(0), nil)
+	value := bv(string(make([]byte, 1000)))
+	for i := 0; i < 200; i++ {
+		c.Add(fmt.Sprintf("key%03d", i), value)
+		if i%2 == 1 {
+			c.Remove(fmt.Sprintf("key%03d", i))
+		}
+	}
+	if len(c.slab) <= initialSize {
+		t.Fatalf("slab should grow, got %d bytes", len(c.slab))
+	}
+	if c.Len() != 100 || c.count > 150 {
+		t.Fatalf("got len %d count %d", c.Len(), c.count)
+	}
+	for i := 0; i < 200; i += 2 {
+		if _, ok := c.Get(fmt.Sprintf("key%03d", i)); !ok {
+			t.Fatalf("key%03d should be cached", i)
+		}
+	}
+}
+
+// TestCache_Collision：测试哈希冲突的 key 互不覆盖，删除、淘汰与扩容后仍能正确查找
+func TestCache_Collision(t *testing.T) {
+	c := New(int64(0), nil)
+	c.hash = func(string) uint64 { return 1 }
+	value := bv(string(make([]byte, 1000)))
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("key%03d", i), value)
+	}
+	c.Remove("key000")
+	c.Add("key001", bv("1"))
+	if len(c.slab) <= initialSize {
+		t.Fatalf("slab should grow, got %d bytes", len(c.slab))
+	}
+	if c.Len() != 99 {
+		t.Fatalf("got len %d, want 99", c.Len())
+	}
+	if _, ok := c.Get("key000"); ok {
+		t.Fatalf("key000 should be removed")
+	}
+	if v, ok := c.Get("key001"); !ok || v.(byteview.ByteView).String() != "1" {
+		t.Fatalf("key001 should be updated")
+	}
+	for i := 2; i < 100; i++ {
+		if _, ok := c.Get(fmt.Sprintf("key%03d", i)); !ok {
+			t.Fatalf("key%03d should be cached", i)
+		}
+	}
+}
+
+// str：不是 ByteView 的 value
+type str string
+
+func (s str) Len() int { return len(s) }
+
+// TestCache_NotByteView：测试 value 不是 ByteView 时视为被淘汰，而不是 panic
+func TestCache_NotByteView(t *testing.T) {
+	var evicted []string
+	c := New(int64(0), func(key string, value lru.Value) {
+		evicted = append(evicted, key)
+	})
+	c.Add("key1", bv("1"))
+	c.Add("key1", str("1"))
+	if _, ok := c.Get("key1"); ok || len(evicted) != 1 {
+		t.Fatalf("got evicted %v, want [key1]", evicted)
+	}
+}
@@ -0,0 +1,85 @@
+package carrotcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 合并本地加载：Getter 实现了 BatchGetter 时，一段时间窗口内并发的缓存未命中不再各自访问数据库，
+// 而是收集到同一个批次中，窗口结束或批次已满时只调用一次 GetMany。
+// 同一个 key 的并发加载在进入批次之前已经经过 singleflight 去重，因此批次中的 key 互不重复。
+
+const (
+	defaultBatchWindow = time.Millisecond // 收集批次的默认时间窗口
+	defaultBatchKeys   = 100              // 每个批次默认最多包含的 key 的数量
+)
+
+// batchLoader：将时间窗口内并发的本地加载合并为一次 BatchGetter.GetMany 调用
+type batchLoader struct {
+	getter  BatchGetter
+	window  time.Duration // 收集批次的时间窗口
+	maxKeys int           // 批次包含的 key 达到该数量时立即执行
+	calls   *AtomicInt    // 调用 GetMany 的次数
+	mu      sync.Mutex    // 保护 pending
+	pending *batch        // 正在收集的批次，为 nil 表示没有
+}
+
+// batch：一次 GetMany 调用，done 关闭后 found 与 err 才可以读取
+type batch struct {
+	keys  []string
+	done  chan struct{}
+	found map[string][]byte
+	err   error
+}
+
+// load：将 key 加入正在收集的批次并等待结果，key 不在返回的 map 中时返回 ErrNotFound，ctx 被取消时放弃等待
+func (l *batchLoader) load(ctx context.Context, key string) ([]byte, error) {
+	l.mu.Lock()
+	b := l.pending
+	if b == nil {
+		b = &batch{done: make(chan struct{})}
+		l.pending = b
+		time.AfterFunc(l.window, func() { l.flush(b) })
+	}
+	b.keys = append(b.keys, key)
+	if len(b.keys) >= l.maxKeys {
+		// 批次已满，立即执行，之后的加载进入新的批次
+		l.pending = nil
+		go l.run(b)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+		if b.err != nil {
+			return nil, b.err
+		}
+		bytes, ok := b.found[key]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return bytes, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush：时间窗口结束时执行批次，批次已经因为达到上限而执行时什么也不做
+func (l *batchLoader) flush(b *batch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+// run：调用一次 GetMany 并唤醒批次中所有的等待者
+func (l *batchLoader) run(b *batch) {
+	l.calls.Add(1)
+	b.found, b.err = l.getter.GetMany(b.keys)
+	close(b.done)
+}
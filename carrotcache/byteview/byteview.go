@@ -1,14 +1,36 @@
 package byteview
 
-// ByteView 主要完成缓存值的抽象与封装，只有一个数据成员，b []byte，b 将会存储真实的缓存值
+import (
+	"time"
+	"unsafe"
+)
+
+// ByteView 主要完成缓存值的抽象与封装，b []byte 将会存储真实的缓存值
 // 选择 byte 类型是为了能够支持任意的数据类型的存储，例如字符串、图片等
 type ByteView struct {
 	B []byte
+	// SoftExpire：软过期时间，零值表示不需要刷新。超过该时间后数据仍然可以返回，但需要在后台重新加载
+	SoftExpire time.Time
+	// Expire：写入缓存时计算的过期时间，零值表示永不过期，超过后数据被缓存删除，传递给其他节点时换算为剩余的过期时间
+	Expire time.Time
+	// Version：源数据的版本号或 ETag，原样保存并传递给其他节点
+	Version string
+	// NoStore：为 true 时数据只返回给调用方，不写入任何缓存
+	NoStore bool
 }
 
-// 我们在 lru.Cache 的实现中，要求被缓存对象必须实现 Value 接口，即 Len() int 方法，返回其所占的内存大小
+// headerSize：ByteView 结构体本身的大小，包括切片头、软过期与过期时间、版本号的字符串头及 NoStore，64 位平台上为 96 字节。
+// 存入缓存时 ByteView 被装箱到 lru.Value 接口中，这部分内存同样需要计入
+const headerSize = int(unsafe.Sizeof(ByteView{}))
+
+// 我们在 lru.Cache 的实现中，要求被缓存对象必须实现 Value 接口，即 Len() int 方法，返回其所占的内存大小，包括版本号及结构体本身
 func (v ByteView) Len() int {
-	return len(v.B)
+	return len(v.B) + len(v.Version) + headerSize
+}
+
+// Stale : 判断数据在 now 时刻是否已经超过软过期时间，需要在后台刷新
+func (v ByteView) Stale(now time.Time) bool {
+	return !v.SoftExpire.IsZero() && now.After(v.SoftExpire)
 }
 
 // ByteSlice : 返回一个拷贝，防止缓存值被外部程序修改。
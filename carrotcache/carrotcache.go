@@ -1,52 +1,263 @@
 package carrotcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
 	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
 	concurrentcache "github.com/Dongxiem/carrotCache/carrotcache/concurrentcache"
+	"github.com/Dongxiem/carrotCache/carrotcache/counter"
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+	"github.com/Dongxiem/carrotCache/carrotcache/hotkey"
+	"github.com/Dongxiem/carrotCache/carrotcache/logger"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
 	peers "github.com/Dongxiem/carrotCache/carrotcache/peers"
 	"github.com/Dongxiem/carrotCache/carrotcache/singleflight"
-	"log"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-//每分钟远程获取的QPS上限
-const maxMinuteRemoteQPS = 100
-
 // 一个 Group 可以认为是一个缓存的命名空间，主要负责与外部交互，控制缓存存储和获取的主流程
 type Group struct {
-	name      string                	// 每个 Group 拥有一个唯一的名称 name
-	getter    Getter                	// 缓存未命中时获取源数据的回调(callback)
-	mainCache concurrentcache.Cache 	// 一开始实现的并发缓存
-	hotCache  concurrentcache.Cache 	// 热点数据
-	peers     peers.PeerPicker			// 节点
-	loader    *singleflight.Group  		// 用于防止缓存击穿，确保高并发下每个 key 仅被提取一次
-	keys      map[string]*KeyStats 		// KeyStats映射
+	stats     Stats                 // Group 的统计信息，原子操作的 64 位字段放在开头以保证在 32 位平台上对齐
+	name      string                // 每个 Group 拥有一个唯一的名称 name
+	getter    Getter                // 缓存未命中时获取源数据的回调(callback)
+	mainCache concurrentcache.Cache // 一开始实现的并发缓存
+	hotCache  concurrentcache.Cache // 热点数据
+	negCache  concurrentcache.Cache // 负缓存，记录源数据中不存在的 key，单独限制内存，不会挤占 mainCache 与 hotCache
+	negTTL    time.Duration         // 负缓存的过期时间，为 0 表示不开启负缓存
+	peers     peers.PeerPicker      // 节点
+	loader    *singleflight.Group   // 用于防止缓存击穿，确保高并发下每个 key 仅被提取一次
+	refresher *singleflight.Group   // 确保每个 key 同时只有一个后台刷新，与 loader 分开，等待加载的调用方不会拿到刷新的结果
+	batcher   *batchLoader          // Getter 实现了 BatchGetter 时合并并发的本地加载，为 nil 表示不合并
+	batchWin  time.Duration         // 合并本地加载的时间窗口，为 0 表示不合并
+	batchKeys int                   // 每次合并最多包含的 key 的数量
+	hotKeys   HotKeyDetector        // 热点 key 探测器，决定哪些远程获取的 key 存入 hotCache
+	demote    time.Duration         // 检查热点 key 是否降温的时间间隔
+	ttl       time.Duration         // 缓存数据的默认过期时间，为 0 表示永不过期
+	softTTL   time.Duration         // 数据写入后多久变为陈旧并在后台刷新，为 0 表示不刷新
+	refreshTO time.Duration         // 每次后台刷新的超时时间
+	maxBytes  int64                 // mainCache 与 hotCache 的总内存上限，为 0 表示不限制
+	cacheByte int64                 // NewGroup 传入的缓存大小
+	hotRatio  float64               // hotCache 占 cacheByte 的比例，为 0 表示使用默认比例
+	split     *adaptiveSplit        // 自适应模式下记录两个缓存的命中情况，为 nil 表示按 hotRatio 固定划分
+	sweep     time.Duration         // 后台清理过期数据的时间间隔
+	logger    logger.Logger         // 日志，默认不输出
+	epochs    []uint64              // 按 key 的哈希分组的失效计数，Set 与 Remove 时递增，单独分配以保证 64 位对齐
+	closeOnce sync.Once             // 保证 Close 只执行一次
+	done      chan struct{}         // Close 时关闭，通知后台协程退出
+}
+
+// Stats：Group 的统计信息，均使用原子类进行维护
+type Stats struct {
+	Gets          AtomicInt // Get 的调用次数
+	MainCacheHits AtomicInt // mainCache 的命中次数
+	HotCacheHits  AtomicInt // hotCache 的命中次数
+	Loads         AtomicInt // 缓存未命中而需要加载的次数 (gets - cacheHits)
+	LoadsExecuted AtomicInt // 经过 singleflight 去重后实际执行加载的次数，Loads 减去该值即为被去重的次数
+	PeerLoads     AtomicInt // 从远程节点加载成功的次数
+	PeerErrors    AtomicInt // 从远程节点加载失败的次数
+	LocalLoads    AtomicInt // 调用 Getter 加载成功的次数
+	LocalLoadErrs AtomicInt // 调用 Getter 加载失败的次数
+	NegativeHits  AtomicInt // 负缓存的命中次数
+	Refreshes     AtomicInt // 后台刷新陈旧数据的次数
+	BatchLoads    AtomicInt // 合并本地加载后调用 BatchGetter.GetMany 的次数
+}
+
+// CacheType：缓存的类型，用于 CacheStats 选择 mainCache 或 hotCache
+type CacheType int
+
+const (
+	MainCache     CacheType = iota + 1 // 存放本节点负责的数据
+	HotCache                           // 存放其他节点负责的热点数据
+	NegativeCache                      // 存放源数据中不存在的 key
+)
+
+const (
+	defaultHotCacheRatio  = 1.0 / 8          // hotCache 占 cacheByte 的默认比例
+	defaultNegativeBytes  = 1 << 20          // 负缓存的默认内存上限
+	defaultSweepInterval  = time.Minute      // 后台清理过期数据的默认时间间隔
+	defaultDemoteInterval = 10 * time.Second // 检查热点 key 是否降温的默认时间间隔
+	defaultRefreshTimeout = 10 * time.Second // 后台刷新的默认超时时间
+	epochStripes          = 256              // 失效计数的分组数量
+)
+
+// ErrNotFound：表示 key 在源数据中不存在，Getter 返回该错误（或用 %w 包装该错误）时才会被负缓存记录，
+// 远程节点通过 cachepb.Response 的 status 字段传递该错误，其他错误仍视为加载失败
+var ErrNotFound = errors.New("carrotcache: key not found")
+
+// HotKeyDetector：热点 key 探测器，远程获取与 hotCache 命中时都会调用 Record
+type HotKeyDetector interface {
+	// Record 记录一次对 key 的访问，返回该 key 当前是否为热点，为热点时存入 hotCache
+	Record(key string) bool
+	// Cooled 返回已经降温的热点 key，这些 key 会被移出 hotCache
+	Cooled() []string
+}
+
+// HotKeyInspector：可选接口，HotKeyDetector 实现后可通过 Group 查询热点 key 的情况，便于调试
+type HotKeyInspector interface {
+	// Estimate 返回 key 访问频率的估计值
+	Estimate(key string) float64
+	// HotKeys 返回当前的热点 key
+	HotKeys() []string
+}
+
+var (
+	_ HotKeyDetector  = (*hotkey.Tracker)(nil)
+	_ HotKeyInspector = (*hotkey.Tracker)(nil)
+	_ HotKeyDetector  = (*hotkey.SketchTracker)(nil)
+	_ HotKeyInspector = (*hotkey.SketchTracker)(nil)
+)
+
+// GroupOption：NewGroup 的可选配置项
+type GroupOption func(*Group)
+
+// WithTTL：设置缓存数据的默认过期时间，Getter 返回的单个 key 的过期时间优先于该值
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithStaleWhileRevalidate：数据写入 softTTL 后变为陈旧，命中陈旧数据时仍然立即返回，同时在后台刷新，
+// 同一个 key 同时只有一次刷新。刷新使用单独的 singleflight，不与正常的加载共享，等待加载的调用方不会拿到刷新的结果。
+// softTTL 应小于数据的过期时间，否则数据在刷新之前就已经过期，不会触发刷新
+func WithStaleWhileRevalidate(softTTL time.Duration) GroupOption {
+	return func(g *Group) {
+		g.softTTL = softTTL
+	}
 }
 
-// 封装一个原子类
-type AtomicInt int64
+// WithRefreshTimeout：设置每次后台刷新的超时时间，超时后取消传给 ContextGetter 及远程节点的 ctx，
+// 使卡住的刷新不会一直占用该 key 的刷新，小于等于 0 时使用默认的 10s。只实现了 Getter 的回调无法感知取消
+func WithRefreshTimeout(timeout time.Duration) GroupOption {
+	return func(g *Group) {
+		if timeout <= 0 {
+			timeout = defaultRefreshTimeout
+		}
+		g.refreshTO = timeout
+	}
+}
 
-// Add：原子自增
-func (i *AtomicInt) Add(n int64) {
-	atomic.AddInt64((*int64)(i), n)
+// WithLogger：设置 Group 的日志，默认不输出任何日志，l 为 nil 时同样不输出
+func WithLogger(l logger.Logger) GroupOption {
+	return func(g *Group) {
+		if l == nil {
+			l = logger.Nop()
+		}
+		g.logger = l
+	}
 }
 
-// Get：原子读取
-func (i *AtomicInt) Get() int64 {
-	return atomic.LoadInt64((*int64)(i))
+// WithHotKeyDetector：设置热点 key 探测器，默认使用基于 Count-Min Sketch 的 hotkey.NewSketch(hotkey.SketchConfig{})
+func WithHotKeyDetector(d HotKeyDetector) GroupOption {
+	return func(g *Group) {
+		g.hotKeys = d
+	}
 }
 
-// KeyStats：Key的统计信息
-type KeyStats struct {
-	firstGetTime time.Time
-	remoteCnt    AtomicInt //利用atomic包封装的原子类
+// WithDemoteInterval：设置检查热点 key 是否降温的时间间隔
+func WithDemoteInterval(interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.demote = interval
+	}
 }
 
+// WithEvictionPolicy：设置 mainCache 与 hotCache 的淘汰策略，默认使用 concurrentcache.LRU，
+// 可选 concurrentcache 中预置的 TinyLFU、ARC、LFU、TwoQ、FIFO、CLOCK，也可以传入自定义的策略
+func WithEvictionPolicy(policy concurrentcache.EvictionPolicy) GroupOption {
+	return func(g *Group) {
+		g.mainCache.Policy = policy
+		g.hotCache.Policy = policy
+	}
+}
+
+// WithMemoryLimit：设置 mainCache 与 hotCache 的总内存上限，统计包括每条数据的额外开销。
+// 两者各自的上限由 NewGroup 的 cacheByte 决定，该上限约束两者之和，超出时参照 groupcache，
+// hotCache 超过 mainCache 的 1/8 时淘汰 hotCache，否则淘汰 mainCache；自适应模式下淘汰边际收益较低的缓存
+func WithMemoryLimit(maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.maxBytes = maxBytes
+	}
+}
+
+// WithHotCacheRatio：设置 hotCache 占 cacheByte 的比例，取值范围为 (0, 1)，默认为 1/8，其余分配给 mainCache。
+// 注意 CacheBytes 为 0 表示不限制，因此比例不能为 0，超出范围时 NewGroup 会 panic。
+// 自适应模式下没有固定的比例，与 WithAdaptiveCacheSplit 同时使用时 NewGroup 同样会 panic
+func WithHotCacheRatio(ratio float64) GroupOption {
+	return func(g *Group) {
+		if !(ratio > 0 && ratio < 1) {
+			panic(fmt.Sprintf("hot cache ratio %v out of range (0, 1)", ratio))
+		}
+		g.hotRatio = ratio
+	}
+}
+
+// WithAdaptiveCacheSplit：mainCache 与 hotCache 不再固定划分，而是共享 cacheByte，初始按照默认比例划分。
+// 写入的缓存预算不足时，先借用另一方未使用的预算，仍不足则根据近期每字节的命中次数估计两者的边际收益，
+// 另一方收益较低时将其预算转移过来，使内存流向更有用的缓存。不能与 WithHotCacheRatio 同时使用
+func WithAdaptiveCacheSplit() GroupOption {
+	return func(g *Group) {
+		g.split = &adaptiveSplit{}
+	}
+}
+
+// WithNegativeCache：开启负缓存，Getter 或所属节点返回 ErrNotFound 时记录该 key，ttl 内再次请求直接返回 ErrNotFound 而不访问数据库。
+// 负缓存单独使用至多 maxBytes 的内存，小于等于 0 时使用默认的 1MB，不计入 mainCache 与 hotCache 的内存
+func WithNegativeCache(ttl time.Duration, maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negTTL = ttl
+		if maxBytes <= 0 {
+			maxBytes = defaultNegativeBytes
+		}
+		g.negCache.CacheBytes = maxBytes
+	}
+}
+
+// WithBatchWindow：Getter 实现了 BatchGetter 时，将 window 时间内并发的本地加载合并为一次 GetMany 调用，
+// 每次最多合并 maxKeys 个 key，小于等于 0 时使用默认的 100；window 小于等于 0 表示不合并。默认的时间窗口为 1ms。
+// Getter 同时实现了 ResultGetter 或 TTLGetter 时不合并，详见 BatchGetter
+func WithBatchWindow(window time.Duration, maxKeys int) GroupOption {
+	return func(g *Group) {
+		g.batchWin = window
+		if maxKeys <= 0 {
+			maxKeys = defaultBatchKeys
+		}
+		g.batchKeys = maxKeys
+	}
+}
+
+// WithCacheShards：将 mainCache 与 hotCache 各自分为 n 个独立加锁的分片，减少多核下的锁竞争
+func WithCacheShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.Shards = n
+		g.hotCache.Shards = n
+	}
+}
+
+// WithBufferedReads：mainCache 与 hotCache 的命中不再立即调整淘汰顺序，而是记录到读缓冲区中异步批量回放，
+// 命中路径只需读锁，适合读多写少的场景
+func WithBufferedReads() GroupOption {
+	return func(g *Group) {
+		g.mainCache.BufferedReads = true
+		g.hotCache.BufferedReads = true
+	}
+}
+
+// WithSweepInterval：设置后台清理过期数据的时间间隔
+func WithSweepInterval(interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.sweep = interval
+	}
+}
+
+// AtomicInt：封装一个原子类，与 lru、concurrentcache 的统计信息共用 counter.AtomicInt
+type AtomicInt = counter.AtomicInt
+
 // Getter：回调接口定义，只包含一个方法 Get
 // 既能够将普通的函数类型（需类型转换）作为参数，也可以将结构体作为参数，使用更为灵活，可读性也更好，这就是接口型函数的价值。
 type Getter interface {
@@ -62,13 +273,112 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// ContextGetter：可选的回调接口，获取源数据时可以感知取消：ctx 携带第一个调用方 ctx 中的值，
+// 在等待该 key 的所有调用方都放弃时被取消
+type ContextGetter interface {
+	Getter
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// ContextGetterFunc：ContextGetter 的接口型函数
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Get：实现 Getter 接口，使用 context.Background()
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+// GetContext：实现 ContextGetter 接口
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// TTLGetter：可选的回调接口，在返回源数据的同时返回该 key 的过期时间
+// ttl 小于等于 0 时使用 Group 的默认过期时间。同时实现了 ContextGetter 时优先使用 TTLGetter 以免丢失过期时间，
+// 需要两者兼得时请实现 ResultGetter
+type TTLGetter interface {
+	Getter
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// TTLGetterFunc：TTLGetter 的接口型函数
+type TTLGetterFunc func(key string) ([]byte, time.Duration, error)
+
+// Get：实现 Getter 接口，使用 Group 的默认过期时间
+func (f TTLGetterFunc) Get(key string) ([]byte, error) {
+	bytes, _, err := f(key)
+	return bytes, err
+}
+
+// GetWithTTL：实现 TTLGetter 接口
+func (f TTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(key)
+}
+
+// Result：ResultGetter 返回的源数据及其元数据
+type Result struct {
+	Value   []byte        // 源数据
+	TTL     time.Duration // 该 key 的过期时间，小于等于 0 时使用 Group 的默认过期时间
+	Version string        // 数据的版本号或 ETag，保存在 ByteView.Version 中并传递给其他节点
+	NoStore bool          // 为 true 时数据只返回给调用方，mainCache 与 hotCache 都不会保存
+}
+
+// ResultGetter：可选的回调接口，在返回源数据的同时返回过期时间、版本号以及是否可以缓存，优先于 ContextGetter 与 TTLGetter
+type ResultGetter interface {
+	Getter
+	GetResult(ctx context.Context, key string) (Result, error)
+}
+
+// ResultGetterFunc：ResultGetter 的接口型函数
+type ResultGetterFunc func(ctx context.Context, key string) (Result, error)
+
+// Get：实现 Getter 接口，使用 context.Background() 并丢弃元数据
+func (f ResultGetterFunc) Get(key string) ([]byte, error) {
+	res, err := f(context.Background(), key)
+	return res.Value, err
+}
+
+// GetResult：实现 ResultGetter 接口
+func (f ResultGetterFunc) GetResult(ctx context.Context, key string) (Result, error) {
+	return f(ctx, key)
+}
+
+// BatchGetter：可选的回调接口，一次从源数据加载多个 key，GetMulti 在本地加载时只调用一次 GetMany。
+// 返回的 map 中不包含的 key 视为不存在，即 ErrNotFound；返回错误时所有 key 均加载失败。
+// GetMany 无法返回每个 key 的元数据，因此同时实现了 ResultGetter 或 TTLGetter 时不会调用 GetMany，以免丢失过期时间、版本号及 NoStore
+type BatchGetter interface {
+	Getter
+	GetMany(keys []string) (map[string][]byte, error)
+}
+
+// BatchGetterFunc：BatchGetter 的接口型函数
+type BatchGetterFunc func(keys []string) (map[string][]byte, error)
+
+// Get：实现 Getter 接口，key 不在返回的 map 中时返回 ErrNotFound
+func (f BatchGetterFunc) Get(key string) ([]byte, error) {
+	found, err := f([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	bytes, ok := found[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes, nil
+}
+
+// GetMany：实现 BatchGetter 接口
+func (f BatchGetterFunc) GetMany(keys []string) (map[string][]byte, error) {
+	return f(keys)
+}
+
 var (
 	mu     sync.RWMutex
 	groups = make(map[string]*Group) // 将所有新生成的 Group 的指针及其对应的名字存储在全局变量 groups 中
 )
 
-// NewGroup： 创建一个新的Group实例
-func NewGroup(name string, cacheByte int64, getter Getter) *Group {
+// NewGroup： 创建一个新的Group实例，opts 为可选配置项
+func NewGroup(name string, cacheByte int64, getter Getter, opts ...GroupOption) *Group {
 	// 如果回调函数为空则报错
 	if getter == nil {
 		panic("nil Getter")
@@ -80,10 +390,47 @@ func NewGroup(name string, cacheByte int64, getter Getter) *Group {
 	g := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: concurrentcache.Cache{CacheBytes: cacheByte * 7 / 8},	// mainCache 为 cacheByte 的 7/8
-		hotCache:  concurrentcache.Cache{CacheBytes: cacheByte / 8},		// hotCache 为 cacheByet 的 1/8
+		cacheByte: cacheByte,
 		loader:    &singleflight.Group{},
-		keys:      map[string]*KeyStats{},
+		refresher: &singleflight.Group{},
+		hotKeys:   hotkey.NewSketch(hotkey.SketchConfig{}),
+		demote:    defaultDemoteInterval,
+		sweep:     defaultSweepInterval,
+		batchWin:  defaultBatchWindow,
+		refreshTO: defaultRefreshTimeout,
+		batchKeys: defaultBatchKeys,
+		logger:    logger.Nop(),
+		epochs:    make([]uint64, epochStripes),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.split != nil && g.hotRatio != 0 {
+		panic("WithHotCacheRatio cannot be used with WithAdaptiveCacheSplit")
+	}
+	if batch, ok := g.batchGetter(); ok && g.batchWin > 0 {
+		g.batcher = &batchLoader{getter: batch, window: g.batchWin, maxKeys: g.batchKeys, calls: &g.stats.BatchLoads}
+	}
+	// 默认 mainCache 为 cacheByte 的 7/8，hotCache 为 cacheByte 的 1/8，自适应模式下以此作为初始预算
+	if g.hotRatio == 0 {
+		g.hotRatio = defaultHotCacheRatio
+	}
+	g.hotCache.CacheBytes = int64(float64(cacheByte) * g.hotRatio)
+	g.mainCache.CacheBytes = cacheByte - g.hotCache.CacheBytes
+	if cacheByte > 0 && g.hotCache.CacheBytes == 0 {
+		// CacheBytes 为 0 表示不限制
+		g.hotCache.CacheBytes, g.mainCache.CacheBytes = 1, cacheByte-1
+	}
+	// 存在过期数据时才需要启动后台清理协程
+	_, withTTL := getter.(TTLGetter)
+	_, withResult := getter.(ResultGetter)
+	if withTTL || withResult || g.ttl > 0 {
+		g.mainCache.StartSweeper(g.sweep)
+		g.hotCache.StartSweeper(g.sweep)
+	}
+	if g.negTTL > 0 {
+		g.negCache.StartSweeper(g.sweep)
 	}
 	groups[name] = g
 	return g
@@ -98,27 +445,458 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// Groups：返回所有使用 NewGroup 创建的 Group，按名称排序
+func Groups() []*Group {
+	mu.RLock()
+	all := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		all = append(all, g)
+	}
+	mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return all
+}
+
 // Get：通过 key 去 cache 取相对应的 value
 func (g *Group) Get(key string) (byteview.ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext：通过 key 去 cache 取相对应的 value，ctx 被取消或超时时放弃等待并返回 ctx.Err()。
+// 同一个 key 的并发加载只执行一次，只要还有其他调用方在等待，加载就会继续完成并写入缓存
+func (g *Group) GetContext(ctx context.Context, key string) (byteview.ByteView, error) {
 	// 如果 key为空，返回空的 ByteView，然后再返回一个 Error
 	if key == "" {
 		return byteview.ByteView{}, fmt.Errorf("key is required")
 	}
+	g.stats.Gets.Add(1)
+
+	if v, ok, err := g.lookupCache(key); ok {
+		return v, err
+	}
+
+	// 如果缓存中不存在，则调用 load 方法去远程节点进行数据的获取，实在没有再去数据库进行数据获取，最后添加到缓存当中。
+	return g.load(ctx, key)
+}
 
+// lookupCache：依次在 mainCache、hotCache 及负缓存中查找 key，ok 为 false 表示缓存未命中，需要加载
+func (g *Group) lookupCache(key string) (value byteview.ByteView, ok bool, err error) {
 	// 从 mainCache 中查找缓存，如果存在则缓存命中，并且返回缓存值
 	if v, ok := g.mainCache.Get(key); ok {
-		log.Println("[carrotCache] hit")
-		return v, nil
+		g.stats.MainCacheHits.Add(1)
+		if logger.Enabled(g.logger, logger.DebugLevel) {
+			g.logger.Debug("cache hit", "group", g.name, "key", key, "cache", "main")
+		}
+		if g.softTTL > 0 && v.Stale(time.Now()) {
+			g.refresh(key, &g.mainCache)
+		}
+		return v, true, nil
 	}
 
 	// 从 hotCache 中进行请求查找
 	if v, ok := g.hotCache.Get(key); ok {
-		log.Printf("[carrotCache (hotCache)] hit")
-		return v, nil
+		g.stats.HotCacheHits.Add(1)
+		// hotCache 命中不再经过远程获取，需要在这里维持该 key 的访问速率
+		g.hotKeys.Record(key)
+		if logger.Enabled(g.logger, logger.DebugLevel) {
+			g.logger.Debug("cache hit", "group", g.name, "key", key, "cache", "hot")
+		}
+		if g.softTTL > 0 && v.Stale(time.Now()) {
+			g.refresh(key, &g.hotCache)
+		}
+		return v, true, nil
 	}
 
-	// 如果缓存中不存在，则调用 load 方法去远程节点进行数据的获取，实在没有再去数据库进行数据获取，最后添加到缓存当中。
-	return g.load(key)
+	// 负缓存命中说明 key 在源数据中不存在，不必再次加载
+	if g.negTTL > 0 {
+		if _, ok := g.negCache.Get(key); ok {
+			g.stats.NegativeHits.Add(1)
+			if logger.Enabled(g.logger, logger.DebugLevel) {
+				g.logger.Debug("cache hit", "group", g.name, "key", key, "cache", "negative")
+			}
+			return byteview.ByteView{}, true, ErrNotFound
+		}
+	}
+	return byteview.ByteView{}, false, nil
+}
+
+// GetMulti：批量获取多个 key 的缓存值，详见 GetMultiContext
+func (g *Group) GetMulti(keys []string) (map[string]byteview.ByteView, map[string]error) {
+	return g.GetMultiContext(context.Background(), keys)
+}
+
+// GetMultiContext：批量获取多个 key 的缓存值，返回获取成功的值以及每个获取失败的 key 对应的错误。
+// 缓存未命中的 key 按照所属节点分组，每个节点只发送一次批量请求；属于本节点的 key 以及远程获取失败的 key 在本地加载，
+// Getter 实现了 BatchGetter 时只调用一次 GetMany，否则逐个调用 Getter。
+// 本地加载与 Get 共享 singleflight，但发往其他节点的批量请求不会与并发的 Get 对同一个 key 的远程获取去重
+func (g *Group) GetMultiContext(ctx context.Context, keys []string) (map[string]byteview.ByteView, map[string]error) {
+	res := &multiResult{
+		values: make(map[string]byteview.ByteView, len(keys)),
+		errs:   make(map[string]error),
+	}
+	seen := make(map[string]bool, len(keys))
+	var misses []string
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if key == "" {
+			res.set(key, byteview.ByteView{}, fmt.Errorf("key is required"))
+			continue
+		}
+		g.stats.Gets.Add(1)
+		if v, ok, err := g.lookupCache(key); ok {
+			res.set(key, v, err)
+			continue
+		}
+		misses = append(misses, key)
+	}
+	g.stats.Loads.Add(int64(len(misses)))
+
+	// 按照所属节点对未命中的 key 进行分组，并发向各节点发送批量请求
+	local := misses
+	if g.peers != nil && len(misses) > 0 {
+		local = nil
+		byPeer := make(map[peers.PeerGetter][]string)
+		for _, key := range misses {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				byPeer[peer] = append(byPeer[peer], key)
+			} else {
+				local = append(local, key)
+			}
+		}
+		var (
+			wg       sync.WaitGroup
+			failedMu sync.Mutex
+			failed   []string
+		)
+		for peer, keys := range byPeer {
+			wg.Add(1)
+			go func(peer peers.PeerGetter, keys []string) {
+				defer wg.Done()
+				if f := g.getMultiFromPeer(ctx, peer, keys, res); len(f) > 0 {
+					failedMu.Lock()
+					failed = append(failed, f...)
+					failedMu.Unlock()
+				}
+			}(peer, keys)
+		}
+		// 等待远程节点的同时加载属于本节点的 key
+		g.getMultiLocally(ctx, local, res)
+		wg.Wait()
+		// 远程获取失败的 key 回退到本地加载
+		local = failed
+	}
+	g.getMultiLocally(ctx, local, res)
+	return res.values, res.errs
+}
+
+// loadLocally：经过 singleflight 在本地加载 key，batcher 不为 nil 时通过 batcher 合并为 GetMany
+func (g *Group) loadLocally(ctx context.Context, key string, batcher *batchLoader) (byteview.ByteView, error) {
+	viewi, err := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.LoadsExecuted.Add(1)
+		return g.getLocallyBatch(ctx, key, batcher)
+	})
+	if err != nil {
+		return byteview.ByteView{}, err
+	}
+	return viewi.(byteview.ByteView), nil
+}
+
+// multiResult：GetMultiContext 的结果，由多个协程并发写入
+type multiResult struct {
+	mu     sync.Mutex
+	values map[string]byteview.ByteView
+	errs   map[string]error
+}
+
+// set：记录 key 的获取结果，err 不为 nil 时表示获取失败
+func (r *multiResult) set(key string, value byteview.ByteView, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errs[key] = err
+		return
+	}
+	r.values[key] = value
+}
+
+// getMultiFromPeer：向远程节点发送一次批量请求，返回需要回退到本地加载的 key。
+// 批量请求不经过 singleflight，不会与并发的 Get 对同一个 key 的远程获取去重，重复的请求由所属节点的缓存及 singleflight 吸收
+func (g *Group) getMultiFromPeer(ctx context.Context, peer peers.PeerGetter, keys []string, res *multiResult) (failed []string) {
+	// 回退到本地加载的 key 会在 loadLocally 中计数，这里只计入由所属节点得出结果的 key
+	defer func() { g.stats.LoadsExecuted.Add(int64(len(keys) - len(failed))) }()
+	epochs := make([]uint64, len(keys))
+	for i, key := range keys {
+		epochs[i] = g.epoch(key)
+	}
+	req := &pb.BatchRequest{
+		Group: g.name,
+		Keys:  keys,
+	}
+	out := &pb.BatchResponse{}
+	err := peer.GetMulti(ctx, req, out)
+	if logger.Enabled(g.logger, logger.DebugLevel) {
+		g.logger.Debug("get multi from peer", "group", g.name, "keys", len(keys))
+	}
+	if err == nil && len(out.GetResponses()) != len(keys) {
+		err = fmt.Errorf("peer returned %d responses for %d keys", len(out.GetResponses()), len(keys))
+	}
+	if err != nil {
+		g.stats.PeerErrors.Add(int64(len(keys)))
+		// 调用方已经放弃，不必再回退到本地加载
+		if ctx.Err() != nil {
+			for _, key := range keys {
+				res.set(key, byteview.ByteView{}, ctx.Err())
+			}
+			return nil
+		}
+		g.logger.Warn("failed to get multi from peer", "group", g.name, "keys", len(keys), "err", err)
+		return keys
+	}
+	for i, key := range keys {
+		r := out.GetResponses()[i]
+		switch r.GetStatus() {
+		case pb.Status_OK:
+			g.stats.PeerLoads.Add(1)
+			value, ttl := viewFromResponse(r)
+			res.set(key, g.recordPeerValue(key, epochs[i], value, ttl), nil)
+		case pb.Status_NOT_FOUND:
+			g.populateNegative(key, epochs[i])
+			res.set(key, byteview.ByteView{}, ErrNotFound)
+		default:
+			g.stats.PeerErrors.Add(1)
+			g.logger.Warn("failed to get from peer", "group", g.name, "key", key, "err", r.GetError())
+			failed = append(failed, key)
+		}
+	}
+	return failed
+}
+
+// getMultiLocally：在本地加载多个 key，每个 key 都经过 singleflight，与并发的 Get 一同去重，ctx 被取消时放弃等待。
+// Getter 实现了 BatchGetter 时，开启合并则与并发的 Get 合并为 GetMany；未开启合并时为这些 key 创建一次性的批次，
+// 没有正在加载的 key 一同调用一次 GetMany。都没有时逐个调用 Getter
+func (g *Group) getMultiLocally(ctx context.Context, keys []string, res *multiResult) {
+	if len(keys) == 0 {
+		return
+	}
+	batch, ok := g.batchGetter()
+	if !ok {
+		for _, key := range keys {
+			value, err := g.loadLocally(ctx, key, nil)
+			res.set(key, value, err)
+		}
+		return
+	}
+	batcher := g.batcher
+	if batcher == nil {
+		// 批次在所有 key 加入后立即执行；部分 key 已经在加载而不会加入时，最多等待一个默认的时间窗口
+		batcher = &batchLoader{getter: batch, window: defaultBatchWindow, maxKeys: len(keys), calls: &g.stats.BatchLoads}
+	}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			value, err := g.loadLocally(ctx, key, batcher)
+			res.set(key, value, err)
+		}(key)
+	}
+	wg.Wait()
+}
+
+// SetOptions：Set 的可选参数
+type SetOptions struct {
+	TTL      time.Duration // 过期时间，小于等于 0 时使用 Group 的默认过期时间
+	HotCache bool          // key 属于其他节点时，是否同时写入本地 hotCache
+}
+
+// Set：将数据写入缓存，详见 SetContext
+func (g *Group) Set(key string, value []byte, opts SetOptions) error {
+	return g.SetContext(context.Background(), key, value, opts)
+}
+
+// SetContext：将数据直接写入缓存，不经过 Getter。
+// key 属于其他节点时，数据会写入所属节点的 mainCache，并根据 opts.HotCache 刷新或移除本地 hotCache 中的副本，
+// 所属节点不可用时本地加载写入 mainCache 的副本同样被移除；否则写入本节点的 mainCache。
+func (g *Group) SetContext(ctx context.Context, key string, value []byte, opts SetOptions) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if g.peers != nil {
+		if owner, ok := g.peers.PickPeer(key); ok {
+			req := &pb.SetRequest{
+				Group: g.name,
+				Key:   key,
+				Value: value,
+				TtlMs: ttlMillis(opts.TTL),
+			}
+			if err := owner.Set(ctx, req, &pb.SetResponse{}); err != nil {
+				return err
+			}
+			g.invalidate(key)
+			// 所属节点获取失败时 load 会回退到本地加载并写入 mainCache，该副本同样已经过时
+			g.mainCache.Remove(key)
+			if opts.HotCache {
+				g.populateCache(key, byteview.ByteView{B: byteview.CloneBytes(value)}, opts.TTL, &g.hotCache)
+			} else {
+				// 本地的 hotCache 副本已经过时，直接移除
+				g.hotCache.Remove(key)
+			}
+			g.negCache.Remove(key)
+			return nil
+		}
+	}
+	g.SetLocally(key, value, opts.TTL)
+	return nil
+}
+
+// SetLocally：只将数据写入本节点的 mainCache，供节点间通信使用，ttl 小于等于 0 时使用默认过期时间
+func (g *Group) SetLocally(key string, value []byte, ttl time.Duration) {
+	// 先使正在进行的加载失效，避免其加载到的旧数据覆盖本次写入
+	g.invalidate(key)
+	g.populateCache(key, byteview.ByteView{B: byteview.CloneBytes(value)}, ttl, &g.mainCache)
+	// 本节点的 hotCache 及负缓存中可能存在旧数据，一并移除
+	g.hotCache.Remove(key)
+	g.negCache.Remove(key)
+}
+
+// Remove：删除 key 对应的缓存，详见 RemoveContext
+func (g *Group) Remove(key string) error {
+	return g.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext：删除 key 对应的缓存。
+// 先移除本地 mainCache 与 hotCache 中的数据，再通知 key 的所属节点删除，
+// 最后广播给其他所有节点，使其 hotCache 中的副本失效。所属节点删除失败时仍然广播，最后返回所属节点的错误。
+func (g *Group) RemoveContext(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	g.RemoveLocally(key)
+	if g.peers == nil {
+		return nil
+	}
+	req := &pb.RemoveRequest{
+		Group: g.name,
+		Key:   key,
+	}
+	// 首先同步通知所属节点，确保之后的加载不会再从所属节点取到旧数据。
+	// 所属节点失败时仍然广播给其他节点，否则所属节点不可用时其他节点的 hotCache 副本反而得不到清理
+	var ownerErr error
+	owner, ok := g.peers.PickPeer(key)
+	if ok {
+		ownerErr = owner.Remove(ctx, req, &pb.RemoveResponse{})
+	}
+	// 然后并发广播给其他节点，返回遇到的第一个错误
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, peer := range g.peers.GetAll() {
+		if ok && peer == owner {
+			continue
+		}
+		wg.Add(1)
+		go func(peer peers.PeerGetter) {
+			defer wg.Done()
+			if err := peer.Remove(ctx, req, &pb.RemoveResponse{}); err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}(peer)
+	}
+	wg.Wait()
+	if ownerErr != nil {
+		// 所属节点仍可能保存着旧数据，期间的加载可能已经将其取回，再次清理本地缓存
+		g.RemoveLocally(key)
+		return ownerErr
+	}
+	return firstErr
+}
+
+// RemoveLocally：只删除本节点 mainCache、hotCache 及负缓存中 key 对应的缓存，供节点间通信使用
+func (g *Group) RemoveLocally(key string) {
+	// 先使正在进行的加载失效，避免其在删除之后写回旧数据
+	g.invalidate(key)
+	g.mainCache.Remove(key)
+	g.hotCache.Remove(key)
+	g.negCache.Remove(key)
+}
+
+// Close：停止 Group 的所有后台协程，包括过期数据的清理、热点 key 的降温及读缓冲区的回放，并将其从 GetGroup 中移除。
+// 重复调用无效，Close 之后不应再使用该 Group
+func (g *Group) Close() {
+	g.closeOnce.Do(func() {
+		close(g.done)
+		g.mainCache.Close()
+		g.hotCache.Close()
+		g.negCache.Close()
+		mu.Lock()
+		if groups[g.name] == g {
+			delete(groups, g.name)
+		}
+		mu.Unlock()
+	})
+}
+
+// Name：返回 Group 的名称
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Stats：返回 Group 统计信息的快照
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:          AtomicInt(g.stats.Gets.Get()),
+		MainCacheHits: AtomicInt(g.stats.MainCacheHits.Get()),
+		HotCacheHits:  AtomicInt(g.stats.HotCacheHits.Get()),
+		Loads:         AtomicInt(g.stats.Loads.Get()),
+		LoadsExecuted: AtomicInt(g.stats.LoadsExecuted.Get()),
+		PeerLoads:     AtomicInt(g.stats.PeerLoads.Get()),
+		PeerErrors:    AtomicInt(g.stats.PeerErrors.Get()),
+		LocalLoads:    AtomicInt(g.stats.LocalLoads.Get()),
+		LocalLoadErrs: AtomicInt(g.stats.LocalLoadErrs.Get()),
+		NegativeHits:  AtomicInt(g.stats.NegativeHits.Get()),
+		Refreshes:     AtomicInt(g.stats.Refreshes.Get()),
+		BatchLoads:    AtomicInt(g.stats.BatchLoads.Get()),
+	}
+}
+
+// HotKeys：返回当前的热点 key，热点 key 探测器未实现 HotKeyInspector 时返回 nil
+func (g *Group) HotKeys() []string {
+	if inspector, ok := g.hotKeys.(HotKeyInspector); ok {
+		return inspector.HotKeys()
+	}
+	return nil
+}
+
+// HotKeyEstimate：返回 key 访问频率的估计值，热点 key 探测器未实现 HotKeyInspector 时返回 false
+func (g *Group) HotKeyEstimate(key string) (float64, bool) {
+	if inspector, ok := g.hotKeys.(HotKeyInspector); ok {
+		return inspector.Estimate(key), true
+	}
+	return 0, false
+}
+
+// InFlightLoads：返回当前经过 singleflight 去重后正在执行的加载数量
+func (g *Group) InFlightLoads() int {
+	return g.loader.InFlight()
+}
+
+// CacheStats：返回指定缓存的统计信息
+func (g *Group) CacheStats(which CacheType) concurrentcache.CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.Stats()
+	case HotCache:
+		return g.hotCache.Stats()
+	case NegativeCache:
+		return g.negCache.Stats()
+	default:
+		return concurrentcache.CacheStats{}
+	}
 }
 
 // RegisterPeers：该方法实现了 PeerPicker 接口的 HTTPPool 注入到 Group 中
@@ -129,27 +907,64 @@ func (g *Group) RegisterPeers(peers peers.PeerPicker) {
 	}
 	// 进行写入
 	g.peers = peers
+	// 有了远程节点才会使用 hotCache，此时开始定期移除降温的热点 key
+	if g.demote > 0 {
+		go g.demoteHotKeys(g.demote)
+	}
+}
+
+// demoteHotKeys：每隔 interval 将已经降温的热点 key 移出 hotCache，直到 Group 被 Close
+func (g *Group) demoteHotKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, key := range g.hotKeys.Cooled() {
+				g.hotCache.Remove(key)
+				if logger.Enabled(g.logger, logger.DebugLevel) {
+					g.logger.Debug("demote hot key", "group", g.name, "key", key)
+				}
+			}
+		case <-g.done:
+			return
+		}
+	}
 }
 
 // load：进行数据获取，尝试本地节点或者其他节点进行缓存数据的获取，都获取不到再去本地数据库获取。
-func (g *Group) load(key string) (value byteview.ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value byteview.ByteView, err error) {
 	// n个协程同时调用了g.Do，fn中的逻辑只会被一个协程执行，这里是实现了 singleflight 的内容，防止缓存穿透。
 	// 使用 g.loader.Do进行包装，确保了并发场景下针对相同的 key，load 过程只会调用一次。
 	// 使用 PickPeer() 方法选择节点，若非本机节点，则调用 getFromPeer() 从远程获取
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
+	// 每个协程等待期间如果自己的 ctx 被取消，会直接返回而不再等待；加载由所有等待者共享，
+	// 因此 fn 使用的 ctx 只有在所有等待者都放弃时才会被取消，详见 singleflight.Group.DoContext
+	g.stats.Loads.Add(1)
+	viewi, err := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
 		// 下面为 fn 方法的具体实现，该方法在多个协程请求的情况下只会执行一次。
+		g.stats.LoadsExecuted.Add(1)
 		// 首先判断 group.peers 缓存节点是否为空，如果不为空，则根据 key 找到相对应的缓存节点 peer
 		if g.peers != nil {
 			if peer, ok := g.peers.PickPeer(key); ok {
 				// 去指定的缓存节点 Peer 根据 key 进行数据的获取请求，并得到数据 value
-				if value, err = g.getFromPeer(peer, key); err == nil {
+				if value, err = g.getFromPeer(ctx, peer, key); err == nil {
+					g.stats.PeerLoads.Add(1)
 					return value, nil
 				}
-				log.Println("[GeeCache] Failed to get from peer", err)
+				// 所属节点确认 key 不存在，同样不必回退到本地加载
+				if errors.Is(err, ErrNotFound) {
+					return nil, err
+				}
+				g.stats.PeerErrors.Add(1)
+				// 所有调用方都已经放弃，不必再回退到本地加载
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				g.logger.Warn("failed to get from peer", "group", g.name, "key", key, "err", err)
 			}
 		}
 		// 若是本机节点或远程节点获取失败，则回退到 getLocally()
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
 	if err == nil {
 		return viewi.(byteview.ByteView), nil
@@ -157,28 +972,281 @@ func (g *Group) load(key string) (value byteview.ByteView, err error) {
 	return
 }
 
-// populateCache：添加数据进指定的 cache（mainCache/hotCache）
-func (g *Group) populateCache(key string, value byteview.ByteView, c *concurrentcache.Cache) {
+// populateCache：添加数据进指定的 cache（mainCache/hotCache），ttl 小于等于 0 时使用默认过期时间，返回记录了过期时间的数据。
+// value.NoStore 为 true 时不写入，并移除 cache 中的旧数据
+func (g *Group) populateCache(key string, value byteview.ByteView, ttl time.Duration, c *concurrentcache.Cache) byteview.ByteView {
+	if value.NoStore {
+		c.Remove(key)
+		return value
+	}
+	value.Expire = g.expireAt(ttl)
+	value.SoftExpire = g.softExpireAt(value.Expire)
+	if g.split != nil && g.cacheByte > 0 {
+		g.rebalance(c, int64(len(key)+value.Len())+lru.EntryOverhead)
+	}
 	// 添加到当前group对应的cache中
-	c.Add(key, value)
+	c.AddWithExpire(key, value, value.Expire)
+	g.enforceMemoryLimit()
+	return value
+}
+
+// populateLoaded：与 populateCache 相同，但只在 key 的失效计数仍为加载开始前读取的 epoch 时写入。
+// 加载期间 key 被 Set 或 Remove 时加载结果已经过时，仍然返回给调用方，但不写入缓存；
+// 写入之后再检查一次，覆盖检查与写入之间发生的 Remove
+func (g *Group) populateLoaded(key string, epoch uint64, value byteview.ByteView, ttl time.Duration, c *concurrentcache.Cache) byteview.ByteView {
+	if g.epoch(key) != epoch {
+		return value
+	}
+	value = g.populateCache(key, value, ttl, c)
+	if g.epoch(key) != epoch {
+		c.Remove(key)
+	}
+	return value
+}
+
+// epoch：返回 key 所在分组当前的失效计数，不同 key 可能共享同一分组，此时只会少写入一次缓存
+func (g *Group) epoch(key string) uint64 {
+	return atomic.LoadUint64(&g.epochs[stripe(key)])
+}
+
+// invalidate：递增 key 所在分组的失效计数，使正在进行的加载不再写入缓存
+func (g *Group) invalidate(key string) {
+	atomic.AddUint64(&g.epochs[stripe(key)], 1)
+}
+
+// stripe：使用 FNV-1a 计算 key 所在的分组
+func stripe(key string) uint32 {
+	return fnvhash.Sum32(key) % epochStripes
+}
+
+// refresh：在后台重新加载陈旧的数据，期间仍然返回旧数据。
+// mainCache 中的数据调用 Getter 重新加载，hotCache 中的数据从所属节点重新获取；
+// 加载失败或超过 refreshTO 时保留旧数据直到其过期，key 已经不存在时将其移除。刷新期间 key 被 Set 或 Remove 时不写入刷新结果，详见 populateLoaded
+func (g *Group) refresh(key string, c *concurrentcache.Cache) {
+	g.refresher.Go(key, func() (interface{}, error) {
+		g.stats.Refreshes.Add(1)
+		ctx, cancel := context.WithTimeout(context.Background(), g.refreshTO)
+		defer cancel()
+		var (
+			value byteview.ByteView
+			err   error
+		)
+		if c == &g.hotCache {
+			value, err = g.refreshFromPeer(ctx, key)
+		} else {
+			value, err = g.getLocally(ctx, key)
+		}
+		if errors.Is(err, ErrNotFound) {
+			c.Remove(key)
+		} else if err != nil {
+			g.logger.Warn("failed to refresh", "group", g.name, "key", key, "err", err)
+		}
+		return value, err
+	})
+}
+
+// refreshFromPeer：从所属节点重新获取 hotCache 中的数据。
+// key 已经不属于其他节点时将其移出 hotCache 并在本地加载；所属节点返回 ErrNotFound 以外的错误时与 load 相同，回退到本地加载，
+// 结果写入 mainCache，之后的查找先命中 mainCache 中的新数据
+func (g *Group) refreshFromPeer(ctx context.Context, key string) (byteview.ByteView, error) {
+	var peer peers.PeerGetter
+	ok := false
+	if g.peers != nil {
+		peer, ok = g.peers.PickPeer(key)
+	}
+	if !ok {
+		g.hotCache.Remove(key)
+		return g.getLocally(ctx, key)
+	}
+	epoch := g.epoch(key)
+	value, ttl, err := g.fetchFromPeer(ctx, peer, key)
+	if err == nil {
+		return g.populateLoaded(key, epoch, value, ttl, &g.hotCache), nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return byteview.ByteView{}, err
+	}
+	g.stats.PeerErrors.Add(1)
+	g.logger.Warn("failed to refresh from peer", "group", g.name, "key", key, "err", err)
+	return g.getLocally(ctx, key)
+}
+
+// populateNegative：开启负缓存时记录 key 在源数据中不存在，negTTL 后过期；加载期间 key 被写入或删除时不记录
+func (g *Group) populateNegative(key string, epoch uint64) {
+	if g.negTTL <= 0 || g.epoch(key) != epoch {
+		return
+	}
+	g.negCache.AddWithExpire(key, byteview.ByteView{}, time.Now().Add(g.negTTL))
+	if g.epoch(key) != epoch {
+		g.negCache.Remove(key)
+	}
+}
+
+// rebalance：自适应模式下写入 c 之前调用，c 的预算不足以容纳 size 字节的新数据时，先借用另一个缓存未使用的预算，
+// 仍不足且另一个缓存的边际收益更低时，从其预算中转移不足的部分，由其按照自身的淘汰策略淘汰；否则由 c 自己淘汰。
+// 两者的预算之和始终为 cacheByte，底层存储都有真实的内存上限，TinyLFU 的准入及 ARC、2Q 的幽灵 key 才能正常工作
+func (g *Group) rebalance(c *concurrentcache.Cache, size int64) {
+	other, hot := &g.hotCache, false
+	if c == &g.hotCache {
+		other, hot = &g.mainCache, true
+	}
+	g.split.budget.Lock()
+	defer g.split.budget.Unlock()
+	need := c.Bytes() + size - c.CacheBytes
+	// 预算至少保留 1 字节，为 0 会被当作不限制
+	if most := other.CacheBytes - 1; need > most {
+		need = most
+	}
+	if need <= 0 {
+		return
+	}
+	move := other.CacheBytes - other.Bytes()
+	if move < need {
+		mainBytes, hotBytes := g.mainCache.Bytes(), g.hotCache.Bytes()
+		if g.split.preferHot(time.Now(), g.stats.MainCacheHits.Get(), g.stats.HotCacheHits.Get(), mainBytes, hotBytes) != hot {
+			move = need
+		}
+	}
+	if move > need {
+		move = need
+	}
+	if move <= 0 {
+		return
+	}
+	// 先缩小另一方的预算，保证两者之和不超过 cacheByte
+	other.SetCacheBytes(other.CacheBytes - move)
+	c.SetCacheBytes(c.CacheBytes + move)
+}
+
+// enforceMemoryLimit：mainCache 与 hotCache 的总内存超出 WithMemoryLimit 设置的上限时不断淘汰，直到满足限制。
+// Bytes 只读取原子计数，每次写入都检查也不会对分片加锁
+func (g *Group) enforceMemoryLimit() {
+	limit := g.maxBytes
+	if limit <= 0 {
+		return
+	}
+	for {
+		mainBytes, hotBytes := g.mainCache.Bytes(), g.hotCache.Bytes()
+		if mainBytes+hotBytes <= limit {
+			return
+		}
+		victim := &g.mainCache
+		if g.split != nil {
+			if g.split.preferHot(time.Now(), g.stats.MainCacheHits.Get(), g.stats.HotCacheHits.Get(), mainBytes, hotBytes) {
+				victim = &g.hotCache
+			}
+		} else if hotBytes > mainBytes/8 {
+			victim = &g.hotCache
+		}
+		victim.RemoveOldest()
+		// 并发写入等情况下可能无法继续淘汰，避免死循环
+		if g.mainCache.Bytes()+g.hotCache.Bytes() >= mainBytes+hotBytes {
+			return
+		}
+	}
+}
+
+// softExpireAt：根据 softTTL 计算软过期时间，返回零值表示不需要刷新；不早于硬过期时间 expire 时刷新没有意义，同样返回零值
+func (g *Group) softExpireAt(expire time.Time) time.Time {
+	if g.softTTL <= 0 {
+		return time.Time{}
+	}
+	soft := time.Now().Add(g.softTTL)
+	if !expire.IsZero() && !soft.Before(expire) {
+		return time.Time{}
+	}
+	return soft
+}
+
+// expireAt：根据 ttl 计算过期时间，返回零值表示永不过期
+func (g *Group) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = g.ttl
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// batchGetter：返回可以用于合并加载的 BatchGetter，Getter 同时实现了 ResultGetter 或 TTLGetter 时返回 false，
+// 因为 GetMany 只返回数据，合并后会丢失每个 key 的元数据
+func (g *Group) batchGetter() (BatchGetter, bool) {
+	switch g.getter.(type) {
+	case ResultGetter, TTLGetter:
+		return nil, false
+	}
+	batch, ok := g.getter.(BatchGetter)
+	return batch, ok
+}
+
+// getLocally：缓存不存在时，调用回调函数获取源数据，开启合并时与其他 key 一同调用 GetMany
+func (g *Group) getLocally(ctx context.Context, key string) (byteview.ByteView, error) {
+	return g.getLocallyBatch(ctx, key, g.batcher)
 }
 
-// getLocally：缓存不存在时，调用回调函数获取源数据
-func (g *Group) getLocally(key string) (byteview.ByteView, error) {
-	// 调用用户回调函数 g.getter.Get() 获取源数据
-	bytes, err := g.getter.Get(key)
+// getLocallyBatch：与 getLocally 相同，batcher 不为 nil 时通过 batcher 调用 GetMany
+func (g *Group) getLocallyBatch(ctx context.Context, key string, batcher *batchLoader) (byteview.ByteView, error) {
+	// 调用用户回调函数获取源数据，开启合并时与其他 key 一同调用 GetMany，实现了 ResultGetter 则同时取得该 key 的元数据，
+	// 实现了 TTLGetter 则同时取得该 key 的过期时间，实现了 ContextGetter 则传递 ctx；
+	// TTLGetter 先于 ContextGetter 判断，同时实现两者的 Getter 不会丢失过期时间
+	var (
+		res Result
+		err error
+	)
+	epoch := g.epoch(key)
+	if batcher != nil {
+		res.Value, err = batcher.load(ctx, key)
+	} else {
+		switch getter := g.getter.(type) {
+		case ResultGetter:
+			res, err = getter.GetResult(ctx, key)
+		case TTLGetter:
+			res.Value, res.TTL, err = getter.GetWithTTL(key)
+		case ContextGetter:
+			res.Value, err = getter.GetContext(ctx, key)
+		default:
+			res.Value, err = g.getter.Get(key)
+		}
+	}
 	if err != nil {
+		g.stats.LocalLoadErrs.Add(1)
+		if errors.Is(err, ErrNotFound) {
+			g.populateNegative(key, epoch)
+		}
 		return byteview.ByteView{}, err
 	}
+	g.stats.LocalLoads.Add(1)
 	// 通过 ByteView 中的 cloneBytes 方法进行拷贝数据赋值给 value，不要影响到原数据
-	value := byteview.ByteView{B: byteview.CloneBytes(bytes)}
+	value := byteview.ByteView{B: byteview.CloneBytes(res.Value), Version: res.Version, NoStore: res.NoStore}
 	// 并且将源数据添加到缓存 mainCache 中，下次再进行 key 的获取就可以从缓存中查找到了
-	g.populateCache(key, value, &g.mainCache)
-	return value, nil
+	return g.populateLoaded(key, epoch, value, res.TTL, &g.mainCache), nil
 }
 
-// getFromPeer：使用实现了 PeerGetter 接口的 httpGetter 从访问远程节点，获取缓存值。
-func (g *Group) getFromPeer(peer peers.PeerGetter, key string) (byteview.ByteView, error) {
+// getFromPeer：使用实现了 PeerGetter 接口的 httpGetter 从访问远程节点，获取缓存值，成为热点的 key 存入 hotCache
+// 所属节点确认 key 不存在时记录到负缓存
+func (g *Group) getFromPeer(ctx context.Context, peer peers.PeerGetter, key string) (byteview.ByteView, error) {
+	epoch := g.epoch(key)
+	value, ttl, err := g.fetchFromPeer(ctx, peer, key)
+	if errors.Is(err, ErrNotFound) {
+		g.populateNegative(key, epoch)
+	}
+	if err != nil {
+		return byteview.ByteView{}, err
+	}
+	return g.recordPeerValue(key, epoch, value, ttl), nil
+}
+
+// recordPeerValue：记录一次远程获取，成为热点时按照所属节点返回的剩余过期时间存入 hotCache，epoch 为获取前 key 的失效计数
+func (g *Group) recordPeerValue(key string, epoch uint64, value byteview.ByteView, ttl time.Duration) byteview.ByteView {
+	if g.hotKeys.Record(key) {
+		return g.populateLoaded(key, epoch, value, ttl, &g.hotCache)
+	}
+	return value
+}
+
+// fetchFromPeer：向远程节点发送请求获取缓存值及其剩余的过期时间，key 不存在时返回 ErrNotFound
+func (g *Group) fetchFromPeer(ctx context.Context, peer peers.PeerGetter, key string) (byteview.ByteView, time.Duration, error) {
 	// 首先进行 Request 的注册
 	req := &pb.Request{
 		Group: g.name,
@@ -187,34 +1255,107 @@ func (g *Group) getFromPeer(peer peers.PeerGetter, key string) (byteview.ByteVie
 	// res 初始为 {}
 	res := &pb.Response{}
 	// 根据 req 获取相对应的 res
-	err := peer.Get(req, res)
-	fmt.Println("getFromPeer", key)
+	err := peer.Get(ctx, req, res)
+	if logger.Enabled(g.logger, logger.DebugLevel) {
+		g.logger.Debug("get from peer", "group", g.name, "key", key)
+	}
 	if err != nil {
-		return byteview.ByteView{}, err
+		return byteview.ByteView{}, 0, err
+	}
+	if res.GetStatus() == pb.Status_NOT_FOUND {
+		return byteview.ByteView{}, 0, ErrNotFound
 	}
 
-	// 远程获取cnt++
-	if stat, ok := g.keys[key]; ok {
-		stat.remoteCnt.Add(1)
-		// 计算 QPS
-		interval := float64(time.Now().Unix()-stat.firstGetTime.Unix()) / 60
-		qps := stat.remoteCnt.Get() / int64(math.Max(1, math.Round(interval)))
-		if qps >= maxMinuteRemoteQPS {
-			// 存入 hotCache
-			g.populateCache(key, byteview.ByteView{B : res.Value}, &g.hotCache)
-			// 删除映射关系,节省内存
-			mu.Lock()
-			delete(g.keys, key)
-			mu.Unlock()
+	// 将该 res.Value 转为 []byte 并且进行返回
+	value, ttl := viewFromResponse(res)
+	return value, ttl, nil
+}
+
+// NewResponse：将缓存值及其元数据转换为节点间通信使用的 cachepb.Response，过期时间换算为剩余的毫秒数
+func NewResponse(view byteview.ByteView) *pb.Response {
+	res := &pb.Response{
+		Value:   view.ByteSlice(),
+		Version: view.Version,
+		NoStore: view.NoStore,
+	}
+	if !view.Expire.IsZero() {
+		// 即将过期的数据至少保留 1ms，避免被请求方当作使用默认过期时间
+		remaining := time.Until(view.Expire)
+		if remaining <= 0 {
+			remaining = time.Millisecond
 		}
-	} else {
-		// 如果是第一次获取
-		g.keys[key] = &KeyStats{
-			firstGetTime: time.Now(),
-			remoteCnt:    1,
+		res.TtlMs = ttlMillis(remaining)
+	}
+	return res
+}
+
+// NewBatchResponse：将 GetMulti 的结果按照 keys 的顺序转换为节点间通信使用的 cachepb.BatchResponse，
+// ErrNotFound 转换为 NOT_FOUND 状态，其他错误转换为 ERROR 状态并携带错误信息
+func NewBatchResponse(keys []string, values map[string]byteview.ByteView, errs map[string]error) *pb.BatchResponse {
+	out := &pb.BatchResponse{Responses: make([]*pb.Response, len(keys))}
+	for i, key := range keys {
+		err := errs[key]
+		switch {
+		case err == nil:
+			out.Responses[i] = NewResponse(values[key])
+		case errors.Is(err, ErrNotFound):
+			out.Responses[i] = &pb.Response{Status: pb.Status_NOT_FOUND}
+		default:
+			out.Responses[i] = &pb.Response{Status: pb.Status_ERROR, Error: err.Error()}
 		}
 	}
+	return out
+}
 
-	// 将该 res.Value 转为 []byte 并且进行返回
-	return byteview.ByteView{B: res.Value}, nil
+// ttlMillis：将 ttl 换算为节点间通信使用的毫秒数，向上取整，因此正的 ttl 至少为 1ms，不会被对方当作使用默认过期时间
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
+
+// viewFromResponse：NewResponse 的逆过程，返回缓存值及其剩余的过期时间
+func viewFromResponse(res *pb.Response) (byteview.ByteView, time.Duration) {
+	value := byteview.ByteView{B: res.GetValue(), Version: res.GetVersion(), NoStore: res.GetNoStore()}
+	return value, time.Duration(res.GetTtlMs()) * time.Millisecond
+}
+
+// adaptiveSplit：自适应模式下估计 mainCache 与 hotCache 的边际收益
+type adaptiveSplit struct {
+	budget   sync.Mutex // 串行化预算的转移，保护 mainCache 与 hotCache 的 CacheBytes
+	mu       sync.Mutex
+	last     time.Time // 上次估计的时间
+	lastMain int64     // 上次估计时 mainCache 的累计命中次数
+	lastHot  int64     // 上次估计时 hotCache 的累计命中次数
+	mainRate float64   // mainCache 近期命中次数的指数加权平均
+	hotRate  float64   // hotCache 近期命中次数的指数加权平均
+}
+
+// splitHalfLife：旧的命中次数按照经过的时间衰减，每经过 splitHalfLife 减半，越大越看重长期的命中情况。
+// 按时间而不是按估计次数衰减，避免淘汰频繁时历史命中被迅速遗忘
+const splitHalfLife = 10 * time.Second
+
+// preferHot：根据累计命中次数与当前内存判断是否应该淘汰 hotCache。
+// 每字节的近期命中次数近似反映了再增加一字节内存能带来的命中，即边际收益，淘汰收益较低的一方；
+// 收益相同时按照 groupcache 的规则，hotCache 超过 mainCache 的 1/8 时淘汰 hotCache
+func (s *adaptiveSplit) preferHot(now time.Time, mainHits, hotHits, mainBytes, hotBytes int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decay := 1.0
+	if !s.last.IsZero() && now.After(s.last) {
+		decay = math.Exp2(-float64(now.Sub(s.last)) / float64(splitHalfLife))
+	}
+	s.mainRate = s.mainRate*decay + float64(mainHits-s.lastMain)
+	s.hotRate = s.hotRate*decay + float64(hotHits-s.lastHot)
+	s.last, s.lastMain, s.lastHot = now, mainHits, hotHits
+	if mainBytes == 0 || hotBytes == 0 {
+		return hotBytes > 0
+	}
+	mainUtility := s.mainRate / float64(mainBytes)
+	hotUtility := s.hotRate / float64(hotBytes)
+	if mainUtility == hotUtility {
+		return hotBytes > mainBytes/8
+	}
+	return hotUtility < mainUtility
 }
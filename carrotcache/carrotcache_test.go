@@ -1,10 +1,25 @@
 package carrotcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+	"github.com/Dongxiem/carrotCache/carrotcache/codec"
+	"github.com/Dongxiem/carrotCache/carrotcache/concurrentcache"
+	"github.com/Dongxiem/carrotCache/carrotcache/hotkey"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+	"github.com/Dongxiem/carrotCache/carrotcache/peers"
 	"log"
+	"math"
 	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -41,6 +56,7 @@ func TestGet(t *testing.T) {
 			}
 			return nil, fmt.Errorf("%s not exist", key)
 		}))
+	defer gee.Close()
 
 	for k, v := range db {
 		if view, err := gee.Get(k); err != nil || view.String() != v {
@@ -59,8 +75,9 @@ func TestGet(t *testing.T) {
 // TestGetGroup：测试取得 Group
 func TestGetGroup(t *testing.T) {
 	groupName := "scores"
-	NewGroup(groupName, 2<<10, GetterFunc(
+	g := NewGroup(groupName, 2<<10, GetterFunc(
 		func(key string) (bytes []byte, err error) { return }))
+	defer g.Close()
 	if group := GetGroup(groupName); group == nil || group.name != groupName {
 		t.Fatalf("group %s not exist", groupName)
 	}
@@ -69,3 +86,1109 @@ func TestGetGroup(t *testing.T) {
 		t.Fatalf("expect nil, but %s got", group.name)
 	}
 }
+
+// TestClose：测试 Close 停止所有后台协程并将 Group 从 GetGroup 中移除
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	g := NewGroup("close", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }),
+		WithTTL(time.Minute), WithNegativeCache(time.Minute, 0), WithBufferedReads())
+	owner := &fakePeer{name: "owner"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	g.Get("Tom")
+	if runtime.NumGoroutine() <= before {
+		t.Fatal("expect background goroutines to be started")
+	}
+
+	g.Close()
+	g.Close()
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= before })
+	if GetGroup("close") != nil {
+		t.Fatal("closed group should be removed")
+	}
+}
+
+// TestGetWithTTL：测试默认过期时间与 TTLGetter 返回的过期时间
+func TestGetWithTTL(t *testing.T) {
+	loads := 0
+	g := NewGroup("ttl", 2<<10, TTLGetterFunc(
+		func(key string) ([]byte, time.Duration, error) {
+			loads++
+			if key == "short" {
+				return []byte(key), 10 * time.Millisecond, nil
+			}
+			return []byte(key), 0, nil
+		}), WithTTL(time.Hour))
+	defer g.Close()
+
+	for _, key := range []string{"short", "long"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"short", "long"} {
+		if view, err := g.Get(key); err != nil || view.String() != key {
+			t.Fatalf("get %s failed: %v", key, err)
+		}
+	}
+	// short 已经过期需要重新加载，long 仍然命中缓存
+	if loads != 3 {
+		t.Fatalf("expect 3 loads, but %d got", loads)
+	}
+}
+
+// TestResultGetter：测试 ResultGetter 返回的过期时间、版本号及 NoStore 生效，并随响应传递给其他节点
+func TestResultGetter(t *testing.T) {
+	loads := 0
+	g := NewGroup("result", 2<<10, ResultGetterFunc(
+		func(ctx context.Context, key string) (Result, error) {
+			loads++
+			switch key {
+			case "short":
+				return Result{Value: []byte(key), TTL: 10 * time.Millisecond, Version: "v1"}, nil
+			case "private":
+				return Result{Value: []byte(key), NoStore: true}, nil
+			}
+			return Result{Value: []byte(key), Version: "v2"}, nil
+		}), WithTTL(time.Hour))
+	defer g.Close()
+
+	for _, key := range []string{"short", "long", "private"} {
+		if _, err := g.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"short", "long", "private"} {
+		if view, err := g.Get(key); err != nil || view.String() != key {
+			t.Fatalf("get %s failed: %v", key, err)
+		}
+	}
+	// short 已经过期、private 不允许缓存都需要重新加载，long 仍然命中缓存
+	if loads != 5 {
+		t.Fatalf("expect 5 loads, but %d got", loads)
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 2 {
+		t.Fatalf("no-store value should not be cached, but %d items got", cs.Items)
+	}
+
+	view, _ := g.Get("long")
+	if view.Version != "v2" {
+		t.Fatalf("expect version v2, but %q got", view.Version)
+	}
+	// 版本号与剩余的过期时间随响应传递给其他节点
+	got, ttl := viewFromResponse(NewResponse(view))
+	if got.Version != "v2" || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("unexpected response version %q and ttl %v", got.Version, ttl)
+	}
+	if view, _ := g.Get("private"); !NewResponse(view).GetNoStore() {
+		t.Fatal("no-store flag should be sent to other peers")
+	}
+}
+
+// TestGetContext：测试 ctx 会传递给 ContextGetter，超时后返回 ctx.Err()
+func TestGetContext(t *testing.T) {
+	release := make(chan struct{})
+	g := NewGroup("context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			select {
+			case <-release:
+				return []byte(key), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+	defer g.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.GetContext(ctx, "slow"); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, but %v got", err)
+	}
+
+	// 第一个调用方超时不影响仍在等待的调用方
+	result := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := g.GetContext(ctx, "shared")
+		result <- err
+	}()
+	go func() {
+		view, err := g.GetContext(context.Background(), "shared")
+		if err == nil && view.String() != "shared" {
+			err = fmt.Errorf("unexpected value %q", view.String())
+		}
+		result <- err
+	}()
+	if err := <-result; err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, but %v got", err)
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("waiter should get the shared load, but %v got", err)
+	}
+}
+
+// ttlContextGetter：同时实现 ContextGetter 与 TTLGetter
+type ttlContextGetter struct {
+	loads int
+}
+
+func (g *ttlContextGetter) Get(key string) ([]byte, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+func (g *ttlContextGetter) GetContext(ctx context.Context, key string) ([]byte, error) {
+	g.loads++
+	return []byte(key), nil
+}
+
+func (g *ttlContextGetter) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	g.loads++
+	return []byte(key), 10 * time.Millisecond, nil
+}
+
+// TestTTLContextGetter：测试同时实现 ContextGetter 与 TTLGetter 时不会丢失过期时间
+func TestTTLContextGetter(t *testing.T) {
+	getter := &ttlContextGetter{}
+	g := NewGroup("ttl-context", 2<<10, getter)
+	defer g.Close()
+	g.Get("Tom")
+	time.Sleep(20 * time.Millisecond)
+	g.Get("Tom")
+	if getter.loads != 2 {
+		t.Fatalf("per-key TTL should be honored, but %d loads got", getter.loads)
+	}
+}
+
+// fakePeer：用于测试的远程节点，记录收到的获取、删除与写入请求
+type fakePeer struct {
+	name     string
+	notFound bool // 为 true 时所有 key 都不存在
+	fail     bool // 为 true 时 Get、GetMulti 与 Remove 请求失败
+	mu       sync.Mutex
+	gets     int
+	batches  [][]string
+	removed  []string
+	sets     map[string]string
+	ttls     map[string]int64 // Set 请求中的 TtlMs
+}
+
+func (p *fakePeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	p.mu.Lock()
+	p.gets++
+	p.mu.Unlock()
+	if p.fail {
+		return errors.New("peer unavailable")
+	}
+	if p.notFound {
+		out.Status = pb.Status_NOT_FOUND
+		return nil
+	}
+	out.Value = []byte(p.name + ":" + in.GetKey())
+	return nil
+}
+
+func (p *fakePeer) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	p.mu.Lock()
+	p.batches = append(p.batches, in.GetKeys())
+	p.mu.Unlock()
+	if p.fail {
+		return errors.New("peer unavailable")
+	}
+	for _, key := range in.GetKeys() {
+		res := &pb.Response{}
+		if p.notFound {
+			res.Status = pb.Status_NOT_FOUND
+		} else {
+			res.Value = []byte(p.name + ":" + key)
+		}
+		out.Responses = append(out.Responses, res)
+	}
+	return nil
+}
+
+func (p *fakePeer) Remove(ctx context.Context, in *pb.RemoveRequest, out *pb.RemoveResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removed = append(p.removed, in.GetKey())
+	if p.fail {
+		return errors.New("peer unavailable")
+	}
+	return nil
+}
+
+func (p *fakePeer) Set(ctx context.Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sets == nil {
+		p.sets = make(map[string]string)
+		p.ttls = make(map[string]int64)
+	}
+	p.sets[in.GetKey()] = string(in.GetValue())
+	p.ttls[in.GetKey()] = in.GetTtlMs()
+	return nil
+}
+
+// fakePicker：用于测试的节点选择器，除 self 中的 key 属于本节点之外，key 总是属于 owner
+type fakePicker struct {
+	owner *fakePeer
+	all   []*fakePeer
+	self  map[string]bool
+}
+
+func (p *fakePicker) PickPeer(key string) (peers.PeerGetter, bool) {
+	if p.owner == nil || p.self[key] {
+		return nil, false
+	}
+	return p.owner, true
+}
+
+func (p *fakePicker) GetAll() []peers.PeerGetter {
+	all := make([]peers.PeerGetter, 0, len(p.all))
+	for _, peer := range p.all {
+		all = append(all, peer)
+	}
+	return all
+}
+
+// TestRemove：测试删除本地缓存，并通知所属节点及广播给其他节点
+func TestRemove(t *testing.T) {
+	loads := 0
+	g := NewGroup("remove", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	defer g.Close()
+	if _, err := g.Get("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	owner, other := &fakePeer{name: "owner"}, &fakePeer{name: "other"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner, other}})
+	g.hotCache.Add("Tom", byteview.ByteView{B: []byte("Tom")})
+
+	if err := g.Remove("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.Get("Tom"); ok {
+		t.Fatal("Tom should be removed from mainCache")
+	}
+	if _, ok := g.hotCache.Get("Tom"); ok {
+		t.Fatal("Tom should be removed from hotCache")
+	}
+	// 所属节点只应收到一次删除请求，其他节点通过广播收到
+	for _, peer := range []*fakePeer{owner, other} {
+		if !reflect.DeepEqual(peer.removed, []string{"Tom"}) {
+			t.Fatalf("%s should receive one remove, but %v got", peer.name, peer.removed)
+		}
+	}
+}
+
+// TestRemoveOwnerError：测试所属节点删除失败时仍然清理本地缓存并广播给其他节点，最后返回所属节点的错误
+func TestRemoveOwnerError(t *testing.T) {
+	g := NewGroup("remove-owner-error", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }))
+	defer g.Close()
+	owner, other := &fakePeer{name: "owner", fail: true}, &fakePeer{name: "other"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner, other}})
+	g.hotCache.Add("Tom", byteview.ByteView{B: []byte("Tom")})
+	if err := g.Remove("Tom"); err == nil {
+		t.Fatal("owner error should be returned")
+	}
+	if _, ok := g.hotCache.Get("Tom"); ok {
+		t.Fatal("Tom should be removed from hotCache")
+	}
+	if !reflect.DeepEqual(other.removed, []string{"Tom"}) {
+		t.Fatalf("other should receive the broadcast, but %v got", other.removed)
+	}
+}
+
+// TestRemoveDuringLoad：加载期间删除 key，加载得到的旧数据不应写回缓存
+func TestRemoveDuringLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	g := NewGroup("remove-during-load", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("stale"), nil
+		}))
+	defer g.Close()
+	done := make(chan error)
+	go func() {
+		view, err := g.Get("Tom")
+		if err == nil && view.String() != "stale" {
+			err = fmt.Errorf("expect stale, but %s got", view)
+		}
+		done <- err
+	}()
+	<-started
+	g.RemoveLocally("Tom")
+	close(release)
+	// 正在等待的调用方仍然得到加载结果
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.Get("Tom"); ok {
+		t.Fatal("value loaded before Remove should not be cached")
+	}
+}
+
+// TestSet：测试写入本地缓存，以及写入所属节点并刷新本地 hotCache
+func TestSet(t *testing.T) {
+	g := NewGroup("set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	defer g.Close()
+	if err := g.Set("Tom", []byte("630"), SetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := g.Get("Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("failed to get value of Tom after Set: %v", err)
+	}
+
+	owner := &fakePeer{name: "owner"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	// 所属节点不可用时回退到本地加载留下的副本，Set 之后不应再被返回
+	g.mainCache.Add("Sam", byteview.ByteView{B: []byte("old")})
+	if err := g.Set("Sam", []byte("567"), SetOptions{HotCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if owner.sets["Sam"] != "567" {
+		t.Fatalf("Set should be routed to the owner, but %v got", owner.sets)
+	}
+	// 不足 1ms 的过期时间向上取整，不能变为 0 而使用所属节点的默认过期时间
+	if err := g.Set("Ann", []byte("1"), SetOptions{TTL: 500 * time.Microsecond}); err != nil {
+		t.Fatal(err)
+	}
+	if owner.ttls["Ann"] != 1 {
+		t.Fatalf("expect ttl 1ms, but %dms got", owner.ttls["Ann"])
+	}
+	if view, ok := g.hotCache.Get("Sam"); !ok || view.String() != "567" {
+		t.Fatal("Sam should be stored in hotCache")
+	}
+	if _, ok := g.mainCache.Get("Sam"); ok {
+		t.Fatal("Sam should not be stored in mainCache")
+	}
+	if view, err := g.Get("Sam"); err != nil || view.String() != "567" {
+		t.Fatalf("expect 567 after Set, but %q got", view.String())
+	}
+}
+
+// TestStats：测试 Group 与缓存的统计信息
+func TestStats(t *testing.T) {
+	g := NewGroup("stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	defer g.Close()
+	g.Get("Tom")
+	g.Get("Tom")
+	g.Get("unknown")
+
+	stats := g.Stats()
+	if stats.Gets.Get() != 3 || stats.MainCacheHits.Get() != 1 || stats.Loads.Get() != 2 ||
+		stats.LoadsExecuted.Get() != 2 || stats.LocalLoads.Get() != 1 || stats.LocalLoadErrs.Get() != 1 {
+		t.Fatalf("unexpected group stats %+v", stats)
+	}
+	cs := g.CacheStats(MainCache)
+	if cs.Items != 1 || cs.Bytes != entrySize("Tom", "630") || cs.Gets != 3 || cs.Hits != 1 {
+		t.Fatalf("unexpected main cache stats %+v", cs)
+	}
+}
+
+// TestCacheHitAllocs：日志不输出调试信息时，缓存命中不应分配内存；WithLogger(nil) 视为不输出
+func TestCacheHitAllocs(t *testing.T) {
+	g := NewGroup("hit-allocs", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }), WithLogger(nil))
+	defer g.Close()
+	g.Get("Tom")
+	if n := testing.AllocsPerRun(100, func() { g.Get("Tom") }); n != 0 {
+		t.Fatalf("expect no allocation on cache hit, but %v got", n)
+	}
+}
+
+// TestHotKey：测试远程获取的 key 成为热点后存入 hotCache，降温后被移出
+func TestHotKey(t *testing.T) {
+	g := NewGroup("hotkey", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }),
+		WithHotKeyDetector(hotkey.New(hotkey.Config{PromoteQPS: 100, HalfLife: 10 * time.Millisecond})),
+		WithDemoteInterval(5*time.Millisecond))
+	defer g.Close()
+	owner := &fakePeer{name: "owner"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	g.Get("Tom")
+	if _, ok := g.hotCache.Get("Tom"); ok {
+		t.Fatal("Tom should not be hot after 1 get")
+	}
+	g.Get("Tom")
+	if view, ok := g.hotCache.Get("Tom"); !ok || view.String() != "owner:Tom" {
+		t.Fatal("Tom should be stored in hotCache after 2 gets")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := g.hotCache.Get("Tom"); ok {
+		t.Fatal("Tom should be demoted from hotCache after cooling down")
+	}
+}
+
+// TestEvictionPolicy：测试为 Group 选择 W-TinyLFU 淘汰策略
+func TestEvictionPolicy(t *testing.T) {
+	g := NewGroup("tinylfu", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }),
+		WithEvictionPolicy(concurrentcache.TinyLFU))
+	defer g.Close()
+	if _, err := g.Get("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.Get("Tom"); !ok {
+		t.Fatal("Tom should be cached")
+	}
+	if g.mainCache.Policy == nil || g.hotCache.Policy == nil {
+		t.Fatal("eviction policy should be applied to both caches")
+	}
+}
+
+// TestMemoryLimit：测试 mainCache 与 hotCache 的总内存不超过 WithMemoryLimit 设置的上限
+func TestMemoryLimit(t *testing.T) {
+	limit := 3 * entrySize("key0", "value0")
+	g := NewGroup("memlimit", 1<<20, GetterFunc(
+		func(key string) ([]byte, error) { return []byte("value" + key[3:]), nil }),
+		WithMemoryLimit(limit))
+	defer g.Close()
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if v, err := g.Get(key); err != nil || v.String() != "value"+key[3:] {
+			t.Fatalf("failed to get %s", key)
+		}
+		if used := g.mainCache.Bytes() + g.hotCache.Bytes(); used > limit {
+			t.Fatalf("memory usage %d exceeds limit %d", used, limit)
+		}
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 3 || cs.Evictions != 7 {
+		t.Fatalf("unexpected main cache stats %+v", cs)
+	}
+}
+
+// TestHotCacheRatio：测试通过 WithHotCacheRatio 调整 mainCache 与 hotCache 的划分
+func TestHotCacheRatio(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	g := NewGroup("ratio-default", 800, getter)
+	defer g.Close()
+	if g.mainCache.CacheBytes != 700 || g.hotCache.CacheBytes != 100 {
+		t.Fatalf("got main %d hot %d, want 700 100", g.mainCache.CacheBytes, g.hotCache.CacheBytes)
+	}
+	g = NewGroup("ratio", 800, getter, WithHotCacheRatio(0.25))
+	defer g.Close()
+	if g.mainCache.CacheBytes != 600 || g.hotCache.CacheBytes != 200 {
+		t.Fatalf("got main %d hot %d, want 600 200", g.mainCache.CacheBytes, g.hotCache.CacheBytes)
+	}
+}
+
+// TestAdaptiveCacheSplit：测试自适应模式下两个缓存共享内存，并优先淘汰边际收益较低的缓存
+func TestAdaptiveCacheSplit(t *testing.T) {
+	entry := entrySize("key00", "value00")
+	g := NewGroup("adaptive", 10*entry, GetterFunc(
+		func(key string) ([]byte, error) { return []byte("value" + key[3:]), nil }),
+		WithAdaptiveCacheSplit())
+	defer g.Close()
+	if g.hotCache.CacheBytes != 10*entry/8 {
+		t.Fatalf("hotCache should start with the default share, got %d", g.hotCache.CacheBytes)
+	}
+	// hotCache 借用 mainCache 未使用的预算，占据一半内存且被频繁命中
+	hot := make([]string, 5)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("hot%02d", i)
+		g.populateCache(hot[i], byteview.ByteView{B: []byte(fmt.Sprintf("value%02d", i))}, 0, &g.hotCache)
+		for j := 0; j < 10; j++ {
+			if _, err := g.Get(hot[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// 只被访问一次的数据不断加载到 mainCache，应该淘汰 mainCache 而不是 hotCache
+	for i := 0; i < 20; i++ {
+		if _, err := g.Get(fmt.Sprintf("key%02d", i)); err != nil {
+			t.Fatal(err)
+		}
+		if used := g.mainCache.Bytes() + g.hotCache.Bytes(); used > 10*entry {
+			t.Fatalf("memory usage %d exceeds budget %d", used, 10*entry)
+		}
+		if budget := g.mainCache.CacheBytes + g.hotCache.CacheBytes; budget != 10*entry {
+			t.Fatalf("budgets sum to %d, want %d", budget, 10*entry)
+		}
+	}
+	for _, key := range hot {
+		if _, ok := g.hotCache.Get(key); !ok {
+			t.Fatalf("hot key %s should survive", key)
+		}
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 5 {
+		t.Fatalf("mainCache should hold the remaining 5 entries, got %d", cs.Items)
+	}
+}
+
+// TestAdaptiveCacheSplitPolicy：测试自适应模式下底层存储拥有真实的内存上限，W-TinyLFU 的准入能够抵御扫描
+func TestAdaptiveCacheSplitPolicy(t *testing.T) {
+	entry := entrySize("key000", "key000")
+	g := NewGroup("adaptive-tinylfu", 20*entry, GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }),
+		WithAdaptiveCacheSplit(), WithEvictionPolicy(concurrentcache.TinyLFU))
+	defer g.Close()
+	for i := 0; i < 20; i++ {
+		if _, err := g.Get("key999"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只被访问一次的数据不断写入 mainCache，访问频率较低的候选者不会被准入，频繁访问的数据得以保留
+	for i := 0; i < 100; i++ {
+		if _, err := g.Get(fmt.Sprintf("key%03d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := g.mainCache.Get("key999"); !ok {
+		t.Fatal("frequently accessed key should survive the scan")
+	}
+	if used := g.mainCache.Bytes() + g.hotCache.Bytes(); used > 20*entry {
+		t.Fatalf("memory usage %d exceeds budget %d", used, 20*entry)
+	}
+}
+
+// TestAdaptiveSplitDecay：测试历史命中次数按照经过的时间衰减，而不是按照估计的次数衰减
+func TestAdaptiveSplitDecay(t *testing.T) {
+	s := &adaptiveSplit{}
+	now := time.Now()
+	if !s.preferHot(now, 100, 0, 100, 100) {
+		t.Fatal("hotCache without hits should be evicted")
+	}
+	// 同一时刻的频繁淘汰不会让 mainCache 的历史命中衰减
+	for i := 0; i < 100; i++ {
+		if !s.preferHot(now, 100, 10, 100, 100) {
+			t.Fatalf("mainCache hits should not decay without elapsed time, round %d", i)
+		}
+	}
+	if s.preferHot(now.Add(10*splitHalfLife), 100, 20, 100, 100) {
+		t.Fatal("mainCache hits should decay after ten half-lives")
+	}
+}
+
+// TestAdaptiveSplitRatio：测试 WithHotCacheRatio 与 WithAdaptiveCacheSplit 不能同时使用
+func TestAdaptiveSplitRatio(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewGroup should panic")
+		}
+	}()
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	NewGroup("adaptive-ratio", 800, getter, WithHotCacheRatio(0.25), WithAdaptiveCacheSplit())
+}
+
+// TestHotCacheRatioRange：测试超出 (0, 1) 的比例与同时使用自适应模式一样会 panic，而不是被静默忽略
+func TestHotCacheRatioRange(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	for _, ratio := range []float64{0, -0.5, 1, 2, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("NewGroup should panic with ratio %v", ratio)
+				}
+			}()
+			NewGroup("ratio-range", 800, getter, WithHotCacheRatio(ratio))
+		}()
+	}
+}
+
+// TestNegativeCache：测试 ErrNotFound 被负缓存记录，其他错误不会被缓存，写入后负缓存失效
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	g := NewGroup("negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			if key == "broken" {
+				return nil, fmt.Errorf("database unavailable")
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithNegativeCache(time.Minute, 0))
+	defer g.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.Get("unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, but %v got", err)
+		}
+	}
+	if stats := g.Stats(); loads != 1 || stats.NegativeHits.Get() != 1 {
+		t.Fatalf("missing key should be loaded once, but %d loads got", loads)
+	}
+	if cs := g.CacheStats(NegativeCache); cs.Items != 1 {
+		t.Fatalf("negative cache should hold 1 item, but %d got", cs.Items)
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 0 || cs.Bytes != 0 {
+		t.Fatal("negative entries should not use mainCache")
+	}
+
+	// 其他错误可能是暂时的，不应该被缓存
+	g.Get("broken")
+	g.Get("broken")
+	if loads != 3 {
+		t.Fatalf("errors other than ErrNotFound should not be cached, but %d loads got", loads)
+	}
+
+	// 写入后负缓存失效
+	if err := g.Set("unknown", []byte("1"), SetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := g.Get("unknown"); err != nil || view.String() != "1" {
+		t.Fatalf("Set should invalidate the negative cache, but %v got", err)
+	}
+
+	// 所属节点返回 NOT_FOUND 时不再回退到本地加载
+	owner := &fakePeer{name: "owner", notFound: true}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	for i := 0; i < 2; i++ {
+		if _, err := g.Get("remote"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound from peer, but %v got", err)
+		}
+	}
+	if owner.gets != 1 || loads != 3 {
+		t.Fatalf("expect 1 peer get and no local load, but %d and %d got", owner.gets, loads-3)
+	}
+}
+
+// TestStaleWhileRevalidate：测试命中陈旧数据时立即返回旧数据，并且只在后台刷新一次
+func TestStaleWhileRevalidate(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	g := NewGroup("stale", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			n := atomic.AddInt32(&loads, 1)
+			if n == 2 {
+				<-release
+			}
+			return []byte(fmt.Sprintf("v%d", n)), nil
+		}), WithTTL(time.Minute), WithStaleWhileRevalidate(20*time.Millisecond))
+	defer g.Close()
+
+	if view, err := g.Get("Tom"); err != nil || view.String() != "v1" {
+		t.Fatalf("got %q, %v", view.String(), err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// 刷新期间仍然返回旧数据，且只会刷新一次
+	for i := 0; i < 5; i++ {
+		if view, err := g.Get("Tom"); err != nil || view.String() != "v1" {
+			t.Fatalf("stale value should be served during refresh, but %q got", view.String())
+		}
+	}
+	close(release)
+	waitFor(t, func() bool {
+		v, ok := g.mainCache.Get("Tom")
+		return ok && v.String() == "v2"
+	})
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Fatalf("expect 2 loads, but %d got", n)
+	}
+	if stats := g.Stats(); stats.Refreshes.Get() != 1 {
+		t.Fatalf("expect 1 refresh, but %d got", stats.Refreshes.Get())
+	}
+
+	// hotCache 中的陈旧数据从所属节点刷新
+	owner := &fakePeer{name: "owner"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	g.populateCache("Sam", byteview.ByteView{B: []byte("old")}, 0, &g.hotCache)
+	time.Sleep(30 * time.Millisecond)
+	if view, err := g.Get("Sam"); err != nil || view.String() != "old" {
+		t.Fatalf("stale hot value should be served, but %q got", view.String())
+	}
+	waitFor(t, func() bool {
+		v, ok := g.hotCache.Get("Sam")
+		return ok && v.String() == "owner:Sam"
+	})
+}
+
+// TestRefreshFallback：测试 hotCache 刷新时所属节点失败或 key 已经属于本节点都回退到本地加载，刷新不与加载共享 singleflight
+func TestRefreshFallback(t *testing.T) {
+	var slowLoads int32
+	release := make(chan struct{})
+	g := NewGroup("refresh-fallback", 1<<16, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Slow" {
+				atomic.AddInt32(&slowLoads, 1)
+				<-release
+			}
+			return []byte("local:" + key), nil
+		}), WithTTL(time.Minute), WithStaleWhileRevalidate(20*time.Millisecond))
+	defer g.Close()
+	owner := &fakePeer{name: "owner", fail: true}
+	picker := &fakePicker{owner: owner, all: []*fakePeer{owner}, self: map[string]bool{"Moved": true, "Slow": true}}
+	g.RegisterPeers(picker)
+	for _, key := range []string{"Sam", "Moved", "Slow"} {
+		g.populateCache(key, byteview.ByteView{B: []byte("old")}, 0, &g.hotCache)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for _, key := range []string{"Sam", "Moved"} {
+		if view, err := g.Get(key); err != nil || view.String() != "old" {
+			t.Fatalf("stale hot value should be served, but %q got", view.String())
+		}
+		waitFor(t, func() bool {
+			v, ok := g.mainCache.Get(key)
+			return ok && v.String() == "local:"+key
+		})
+		if view, err := g.Get(key); err != nil || view.String() != "local:"+key {
+			t.Fatalf("got %q, want local:%s", view.String(), key)
+		}
+	}
+	if _, ok := g.hotCache.Get("Moved"); ok {
+		t.Fatal("key no longer owned by a peer should leave hotCache")
+	}
+	// 刷新阻塞期间 key 被删除，之后的加载不会等待刷新，也不会拿到刷新的结果
+	if view, err := g.Get("Slow"); err != nil || view.String() != "old" {
+		t.Fatalf("got %q, want old", view.String())
+	}
+	g.RemoveLocally("Slow")
+	done := make(chan error)
+	go func() {
+		_, err := g.Get("Slow")
+		done <- err
+	}()
+	waitFor(t, func() bool { return g.InFlightLoads() == 1 })
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&slowLoads); n != 2 {
+		t.Fatalf("load should not join the refresh, got %d getter calls", n)
+	}
+}
+
+// TestRefreshTimeout：测试卡住的刷新在超时后被取消，保留旧数据，之后命中陈旧数据时可以再次刷新
+func TestRefreshTimeout(t *testing.T) {
+	var loads int32
+	g := NewGroup("refresh-timeout", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			n := atomic.AddInt32(&loads, 1)
+			if n == 2 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return []byte(fmt.Sprintf("v%d", n)), nil
+		}), WithTTL(time.Minute), WithStaleWhileRevalidate(20*time.Millisecond), WithRefreshTimeout(30*time.Millisecond))
+	defer g.Close()
+
+	if view, err := g.Get("Tom"); err != nil || view.String() != "v1" {
+		t.Fatalf("got %q, %v", view.String(), err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if view, err := g.Get("Tom"); err != nil || view.String() != "v1" {
+		t.Fatalf("stale value should be served during refresh, but %q got", view.String())
+	}
+	waitFor(t, func() bool {
+		if view, err := g.Get("Tom"); err != nil || (view.String() != "v1" && view.String() != "v3") {
+			t.Fatalf("got %q, %v", view.String(), err)
+		}
+		v, ok := g.mainCache.Get("Tom")
+		return ok && v.String() == "v3"
+	})
+	if stats := g.Stats(); stats.Refreshes.Get() != 2 {
+		t.Fatalf("expect 2 refreshes, but %d got", stats.Refreshes.Get())
+	}
+}
+
+// entrySize：一条数据在 mainCache 或 hotCache 中所占用的内存，包括 ByteView 结构体及每条数据的额外开销
+func entrySize(key, value string) int64 {
+	return int64(len(key)+byteview.ByteView{B: []byte(value)}.Len()) + lru.EntryOverhead
+}
+
+// waitFor：等待后台协程使 cond 成立，超时则测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestGetMulti：测试批量获取时每个节点只收到一次请求，本地未命中的 key 只调用一次 GetMany
+func TestGetMulti(t *testing.T) {
+	var calls [][]string
+	g := NewGroup("multi", 2<<10, BatchGetterFunc(
+		func(keys []string) (map[string][]byte, error) {
+			keys = append([]string(nil), keys...)
+			sort.Strings(keys)
+			calls = append(calls, keys)
+			found := make(map[string][]byte)
+			for _, key := range keys {
+				if v, ok := db[key]; ok {
+					found[key] = []byte(v)
+				}
+			}
+			return found, nil
+		}))
+	defer g.Close()
+	owner := &fakePeer{name: "owner"}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner},
+		self: map[string]bool{"Tom": true, "Jack": true, "Sam": true, "unknown": true}})
+	g.Get("Tom")
+	calls = nil
+
+	values, errs := g.GetMulti([]string{"Tom", "Jack", "Sam", "unknown", "a", "b", "a", ""})
+	for key, expect := range map[string]string{"Tom": "630", "Jack": "589", "Sam": "567", "a": "owner:a", "b": "owner:b"} {
+		if values[key].String() != expect {
+			t.Fatalf("value of %s should be %q, but %q got", key, expect, values[key].String())
+		}
+	}
+	if !errors.Is(errs["unknown"], ErrNotFound) || errs[""] == nil || len(errs) != 2 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	// Tom 已经缓存，其余本地 key 只调用一次 GetMany；远程 key 去重后只发送一次批量请求
+	if !reflect.DeepEqual(calls, [][]string{{"Jack", "Sam", "unknown"}}) {
+		t.Fatalf("expect one GetMany call, but %v got", calls)
+	}
+	if !reflect.DeepEqual(owner.batches, [][]string{{"a", "b"}}) {
+		t.Fatalf("expect one batch request, but %v got", owner.batches)
+	}
+	if v, ok := g.mainCache.Get("Jack"); !ok || v.String() != "589" {
+		t.Fatal("Jack should be cached after GetMulti")
+	}
+}
+
+// TestGetMultiPeerError：测试批量请求失败时回退到本地加载，每个 key 只计入一次实际执行的加载
+func TestGetMultiPeerError(t *testing.T) {
+	g := NewGroup("multi-peer-error", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) { return []byte("local:" + key), nil }))
+	defer g.Close()
+	owner := &fakePeer{name: "owner", fail: true}
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	values, errs := g.GetMulti([]string{"a", "b"})
+	if len(errs) != 0 || values["a"].String() != "local:a" || values["b"].String() != "local:b" {
+		t.Fatalf("got %v %v", values, errs)
+	}
+	if stats := g.Stats(); stats.LoadsExecuted.Get() != 2 || stats.PeerErrors.Get() != 2 || stats.LocalLoads.Get() != 2 {
+		t.Fatalf("unexpected stats: executed %d, peer errors %d, local loads %d",
+			stats.LoadsExecuted.Get(), stats.PeerErrors.Get(), stats.LocalLoads.Get())
+	}
+}
+
+// TestNewBatchResponse：测试 GetMulti 的结果按照 keys 的顺序转换为节点间通信使用的状态
+func TestNewBatchResponse(t *testing.T) {
+	keys := []string{"a", "missing", "broken"}
+	values := map[string]byteview.ByteView{"a": {B: []byte("1"), Version: "v1"}}
+	errs := map[string]error{"missing": fmt.Errorf("wrapped: %w", ErrNotFound), "broken": errors.New("boom")}
+	res := NewBatchResponse(keys, values, errs).GetResponses()
+	if len(res) != 3 {
+		t.Fatalf("expect 3 responses, but %d got", len(res))
+	}
+	if res[0].GetStatus() != pb.Status_OK || string(res[0].GetValue()) != "1" || res[0].GetVersion() != "v1" {
+		t.Fatalf("unexpected response %v", res[0])
+	}
+	if res[1].GetStatus() != pb.Status_NOT_FOUND {
+		t.Fatalf("ErrNotFound should map to NOT_FOUND, got %v", res[1])
+	}
+	if res[2].GetStatus() != pb.Status_ERROR || res[2].GetError() != "boom" {
+		t.Fatalf("other errors should map to ERROR, got %v", res[2])
+	}
+}
+
+// TestGetMultiUnbatched：测试未开启合并时批量获取同样经过 singleflight，与正在进行的 Get 去重，并且遵守 ctx
+func TestGetMultiUnbatched(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [][]string
+	)
+	release := make(chan struct{})
+	g := NewGroup("multi-unbatched", 2<<10, BatchGetterFunc(
+		func(keys []string) (map[string][]byte, error) {
+			mu.Lock()
+			calls = append(calls, append([]string(nil), keys...))
+			mu.Unlock()
+			if keys[0] == "Slow" {
+				<-release
+			}
+			found := make(map[string][]byte)
+			for _, key := range keys {
+				found[key] = []byte(key)
+			}
+			return found, nil
+		}), WithBatchWindow(0, 0))
+	defer g.Close()
+	done := make(chan error)
+	go func() {
+		_, err := g.Get("Slow")
+		done <- err
+	}()
+	waitFor(t, func() bool { return g.InFlightLoads() == 1 })
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	values, errs := g.GetMulti([]string{"Slow", "Tom", "Sam"})
+	if err := <-done; err != nil || len(errs) != 0 || len(values) != 3 {
+		t.Fatalf("got %v %v %v", err, values, errs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 || len(calls[1]) != 2 {
+		t.Fatalf("Slow should join the in-flight Get, but %v got", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, errs := g.GetMultiContext(ctx, []string{"Jack"}); !errors.Is(errs["Jack"], context.Canceled) {
+		t.Fatalf("expect context.Canceled, but %v got", errs["Jack"])
+	}
+}
+
+// resultBatchGetter：同时实现 ResultGetter 与 BatchGetter 的 Getter
+type resultBatchGetter struct {
+	ResultGetterFunc
+	many int32 // GetMany 的调用次数
+}
+
+func (g *resultBatchGetter) GetMany(keys []string) (map[string][]byte, error) {
+	atomic.AddInt32(&g.many, 1)
+	return nil, errors.New("GetMany should not be called")
+}
+
+// TestResultBatchGetter：测试 Getter 同时实现 ResultGetter 与 BatchGetter 时不合并加载，元数据不会丢失
+func TestResultBatchGetter(t *testing.T) {
+	getter := &resultBatchGetter{ResultGetterFunc: func(ctx context.Context, key string) (Result, error) {
+		return Result{Value: []byte(key), TTL: time.Minute, Version: "v1", NoStore: key == "Sam"}, nil
+	}}
+	g := NewGroup("result-batch", 2<<10, getter)
+	defer g.Close()
+	values, errs := g.GetMulti([]string{"Tom", "Sam"})
+	if len(errs) != 0 || atomic.LoadInt32(&getter.many) != 0 {
+		t.Fatalf("got errs %v and %d GetMany calls", errs, getter.many)
+	}
+	if v := values["Tom"]; v.Version != "v1" || v.Expire.IsZero() {
+		t.Fatalf("metadata should be kept, got version %q expire %v", v.Version, v.Expire)
+	}
+	if _, ok := g.mainCache.Get("Sam"); ok {
+		t.Fatal("NoStore value should not be cached")
+	}
+}
+
+// TestBatchGetter：测试时间窗口内并发的本地加载被合并为一次 GetMany，同一个 key 仍经过 singleflight 去重
+func TestBatchGetter(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [][]string
+	)
+	getter := BatchGetterFunc(func(keys []string) (map[string][]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append([]string(nil), keys...)
+		sort.Strings(keys)
+		calls = append(calls, keys)
+		found := make(map[string][]byte)
+		for _, key := range keys {
+			if v, ok := db[key]; ok {
+				found[key] = []byte(v)
+			}
+		}
+		return found, nil
+	})
+	g := NewGroup("batch", 2<<10, getter, WithBatchWindow(20*time.Millisecond, 0))
+	defer g.Close()
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"Tom", "Jack", "Sam", "unknown", "Tom", "Jack"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			view, err := g.Get(key)
+			if key == "unknown" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("expect ErrNotFound, but %v got", err)
+				}
+			} else if err != nil || view.String() != db[key] {
+				t.Errorf("failed to get value of %s", key)
+			}
+		}(key)
+	}
+	wg.Wait()
+	if !reflect.DeepEqual(calls, [][]string{{"Jack", "Sam", "Tom", "unknown"}}) {
+		t.Fatalf("expect one GetMany call, but %v got", calls)
+	}
+	if stats := g.Stats(); stats.BatchLoads.Get() != 1 {
+		t.Fatalf("expect 1 batch load, but %d got", stats.BatchLoads.Get())
+	}
+
+	// 批次已满时立即执行，不必等待时间窗口结束
+	calls = nil
+	g = NewGroup("batch-full", 2<<10, getter, WithBatchWindow(time.Minute, 2))
+	defer g.Close()
+	start := time.Now()
+	values, errs := g.GetMulti([]string{"Tom", "Jack"})
+	if len(values) != 2 || len(errs) != 0 || time.Since(start) > time.Second {
+		t.Fatalf("full batch should run immediately, got %v, %v", values, errs)
+	}
+	if !reflect.DeepEqual(calls, [][]string{{"Jack", "Tom"}}) {
+		t.Fatalf("expect one GetMany call, but %v got", calls)
+	}
+}
+
+// countingCodec：记录 Unmarshal 的调用次数
+type countingCodec struct {
+	codec.JSON[map[string]int]
+	decodes int32
+}
+
+func (c *countingCodec) Unmarshal(data []byte) (map[string]int, error) {
+	atomic.AddInt32(&c.decodes, 1)
+	return c.JSON.Unmarshal(data)
+}
+
+func TestTypedGroup(t *testing.T) {
+	c := &countingCodec{}
+	g := NewGroup("typed", 2<<10, NewTypedGetter[map[string]int](c,
+		func(ctx context.Context, key string) (map[string]int, error) {
+			if key == "unknown" {
+				return nil, ErrNotFound
+			}
+			return map[string]int{key: len(key)}, nil
+		}))
+	defer g.Close()
+	typed := NewTypedGroup[map[string]int](g, c, WithDecodedCache(1<<10))
+
+	for i := 0; i < 3; i++ {
+		if v, err := typed.Get("Tom"); err != nil || v["Tom"] != 3 {
+			t.Fatalf("expect map[Tom:3], but %v (%v) got", v, err)
+		}
+	}
+	// 缓存中的字节没有变化，只需要解码一次
+	if c.decodes != 1 {
+		t.Fatalf("expect 1 decode, but %d got", c.decodes)
+	}
+	if _, err := typed.Get("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound, but %v got", err)
+	}
+
+	// 写入新值后重新解码
+	if err := typed.Set("Tom", map[string]int{"Tom": 630}, SetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := typed.Get("Tom"); v["Tom"] != 630 || c.decodes != 2 {
+		t.Fatalf("Set should update the decoded value, but %v got after %d decodes", v, c.decodes)
+	}
+	// 绕过 TypedGroup 写入的字节不同，同样需要重新解码
+	g.SetLocally("Tom", []byte(`{"Tom":1}`), 0)
+	if v, _ := typed.Get("Tom"); v["Tom"] != 1 {
+		t.Fatalf("stale decoded value %v returned", v)
+	}
+
+	g.SetLocally("broken", []byte("{"), 0)
+	values, errs := typed.GetMulti([]string{"Sam", "broken"})
+	if values["Sam"]["Sam"] != 3 || errs["broken"] == nil {
+		t.Fatalf("unexpected GetMulti result %v, %v", values, errs)
+	}
+}
@@ -0,0 +1,158 @@
+package clock
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// Cache：CLOCK（二次机会）缓存，不是并发安全的。
+// 所有节点组成一个环，每个节点带有一个访问位，命中时只需置位而无需移动节点。
+// 淘汰时指针沿环转动：访问位为 1 的节点被清零并获得第二次机会，遇到访问位为 0 的节点则将其淘汰。
+type Cache struct {
+	maxData   int64                    // 允许使用最大内存，为 0 表示不限制
+	nowData   int64                    // 当前已使用内存，包括每条数据的额外开销
+	ring      *list.List               // 用链表模拟环，末尾的下一个节点是开头
+	hand      *list.Element            // 时钟指针，指向下一个待检查的节点
+	cache     map[string]*list.Element // 键是字符串，值是链表中对应节点的指针
+	OnEvicted func(key string, value lru.Value)
+}
+
+// entry：环上节点的数据类型
+type entry struct {
+	lru.Entry
+	referenced bool // 访问位
+}
+
+// entryOverhead：每条数据的额外开销，包括链表节点、entry 结构体及 map
+const entryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(entry{})) + lru.MapEntryOverhead
+
+// New：实例化 CLOCK 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxData:   maxData,
+		ring:      list.New(),
+		cache:     make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// Get：根据 key 查找 value，命中时设置访问位
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*entry)
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	e.referenced = true
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*entry); !e.Expired(time.Now()) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*entry)
+		c.nowData += int64(value.Len()) - int64(e.Value.Len())
+		e.Value = value
+		e.Expire = expire
+		e.referenced = true
+	} else {
+		// 新节点插入到指针之前，即指针转一整圈后才会检查到它
+		e := &entry{Entry: lru.Entry{Key: key, Value: value, Expire: expire}}
+		if c.hand == nil {
+			c.cache[key] = c.ring.PushBack(e)
+		} else {
+			c.cache[key] = c.ring.InsertBefore(e, c.hand)
+		}
+		c.nowData += int64(len(key)) + int64(value.Len()) + entryOverhead
+	}
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest：转动时钟指针，淘汰第一个访问位为 0 的节点
+func (c *Cache) RemoveOldest() {
+	if c.ring.Len() == 0 {
+		return
+	}
+	for {
+		if c.hand == nil {
+			c.hand = c.ring.Front()
+		}
+		e := c.hand.Value.(*entry)
+		if !e.referenced {
+			c.removeElement(c.hand)
+			return
+		}
+		// 给予第二次机会，最多转一圈后所有访问位都被清零，因此循环必然结束
+		e.referenced = false
+		c.hand = c.hand.Next()
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	return lru.RemoveExpiredElements(c.removeElement, c.ring)
+}
+
+// Len：获取 Cache 添加了多少条数据
+func (c *Cache) Len() int {
+	return c.ring.Len()
+}
+
+// Bytes：获取 Cache 当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，为 0 表示不限制，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// removeElement：移除环上的节点并维护映射关系与内存值，被移除的节点是指针所指时，指针前进一格
+func (c *Cache) removeElement(ele *list.Element) {
+	if ele == c.hand {
+		c.hand = ele.Next()
+	}
+	c.ring.Remove(ele)
+	e := ele.Value.(*entry)
+	delete(c.cache, e.Key)
+	c.nowData -= e.Size(entryOverhead)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
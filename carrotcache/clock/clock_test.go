@@ -0,0 +1,27 @@
+package clock
+
+import "testing"
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_SecondChance：测试被访问过的数据获得第二次机会
+func TestCache_SecondChance(t *testing.T) {
+	c := New(int64(len("key1v1key2v2key3v3"))+3*entryOverhead, nil)
+	c.Add("key1", String("v1"))
+	c.Add("key2", String("v2"))
+	c.Add("key3", String("v3"))
+	c.Get("key1")
+	c.Add("key4", String("v4")) // key1 的访问位被清零，key2 被淘汰
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("key2 should be evicted")
+	}
+	for _, key := range []string{"key1", "key3", "key4"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("%s should survive", key)
+		}
+	}
+}
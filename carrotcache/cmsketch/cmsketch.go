@@ -0,0 +1,96 @@
+package cmsketch
+
+import (
+	"math"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+)
+
+// depth：哈希函数（即计数器行）的数量
+const depth = 4
+
+// Sketch：Count-Min Sketch，使用固定大小的内存估计每个 key 出现的次数。
+// 每个 key 通过 depth 个哈希函数映射到每一行的一个计数器上，估计值取这些计数器中的最小值，
+// 因此估计值只会偏大不会偏小。Sketch 不是并发安全的，需要调用方加锁。
+type Sketch struct {
+	rows [depth][]uint32
+	mask uint64 // 每行计数器数量减一，计数器数量为 2 的幂
+}
+
+// New：创建每行包含 width 个计数器的 Sketch，width 会被向上取整为 2 的幂
+func New(width int) *Sketch {
+	if width < 1 {
+		width = 1
+	}
+	n := 1
+	for n < width {
+		n <<= 1
+	}
+	s := &Sketch{mask: uint64(n - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint32, n)
+	}
+	return s
+}
+
+// Add：记录 key 出现一次，并返回记录后的估计值。
+// 采用保守更新，只增加值等于当前最小值的计数器，以减小哈希冲突带来的误差。
+func (s *Sketch) Add(key string) uint32 {
+	h1, h2 := hash(key)
+	min := s.estimate(h1, h2)
+	if min == math.MaxUint32 {
+		return min
+	}
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] == min {
+			s.rows[i][idx]++
+		}
+	}
+	return min + 1
+}
+
+// Estimate：返回 key 出现次数的估计值
+func (s *Sketch) Estimate(key string) uint32 {
+	h1, h2 := hash(key)
+	return s.estimate(h1, h2)
+}
+
+// Halve：将所有计数器减半，使过去的访问随时间逐渐衰减
+func (s *Sketch) Halve() {
+	for i := range s.rows {
+		row := s.rows[i]
+		for j := range row {
+			row[j] >>= 1
+		}
+	}
+}
+
+// Clear：将所有计数器清零
+func (s *Sketch) Clear() {
+	for i := range s.rows {
+		row := s.rows[i]
+		for j := range row {
+			row[j] = 0
+		}
+	}
+}
+
+// estimate：返回 key 对应的各行计数器中的最小值
+func (s *Sketch) estimate(h1, h2 uint64) uint32 {
+	min := uint32(math.MaxUint32)
+	for i := range s.rows {
+		if v := s.rows[i][(h1+uint64(i)*h2)&s.mask]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// hash：计算 key 的 64 位 FNV-1a 哈希值，并由此派生两个哈希值，
+// 第 i 行使用 h1 + i*h2 作为下标（double hashing）
+func hash(key string) (uint64, uint64) {
+	h := fnvhash.Sum64(key)
+	// h2 必须为奇数，保证各行的下标不会重合
+	return h, (h>>32 | h<<32) | 1
+}
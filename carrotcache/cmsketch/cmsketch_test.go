@@ -0,0 +1,39 @@
+package cmsketch
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestEstimate：测试估计值不会偏小，且在宽度足够时接近真实值
+func TestEstimate(t *testing.T) {
+	s := New(1024)
+	for i := 0; i < 100; i++ {
+		s.Add("Tom")
+	}
+	for i := 0; i < 500; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	if v := s.Estimate("Tom"); v < 100 || v > 105 {
+		t.Fatalf("estimate of Tom should be about 100, but %d got", v)
+	}
+	if v := s.Estimate("unknown"); v > 5 {
+		t.Fatalf("estimate of unknown should be small, but %d got", v)
+	}
+}
+
+// TestHalve：测试计数器减半与清零
+func TestHalve(t *testing.T) {
+	s := New(16)
+	for i := 0; i < 10; i++ {
+		s.Add("Tom")
+	}
+	s.Halve()
+	if v := s.Estimate("Tom"); v != 5 {
+		t.Fatalf("expect 5 after halving, but %d got", v)
+	}
+	s.Clear()
+	if v := s.Estimate("Tom"); v != 0 {
+		t.Fatalf("expect 0 after clear, but %d got", v)
+	}
+}
@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// 缓存中保存的始终是 []byte，codec 负责在具体的类型 T 与 []byte 之间转换，
+// 供 carrotcache.TypedGroup 使用，调用方不再需要围绕 ByteView.ByteSlice() 手动编解码。
+
+// Codec：类型 T 的编解码器，Marshal 的结果经过 Unmarshal 后应当得到相同的值
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSON：使用 encoding/json 编解码，可读性好，适合与其他语言共享数据
+type JSON[T any] struct{}
+
+// Marshal：实现 Codec 接口
+func (JSON[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal：实现 Codec 接口
+func (JSON[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// Gob：使用 encoding/gob 编解码，只适合 Go 程序之间共享数据，T 为接口类型时需要先调用 gob.Register
+type Gob[T any] struct{}
+
+// Marshal：实现 Codec 接口
+func (Gob[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal：实现 Codec 接口
+func (Gob[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// Proto：返回使用 protobuf 编解码的 Codec，M 为生成的消息类型，例如 codec.Proto[cachepb.Request]() 得到 Codec[*cachepb.Request]
+func Proto[M any, P interface {
+	*M
+	proto.Message
+}]() Codec[P] {
+	return protoCodec[M, P]{}
+}
+
+// protoCodec：Proto 返回的 Codec
+type protoCodec[M any, P interface {
+	*M
+	proto.Message
+}] struct{}
+
+// Marshal：实现 Codec 接口
+func (protoCodec[M, P]) Marshal(v P) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+// Unmarshal：实现 Codec 接口，每次返回新分配的消息
+func (protoCodec[M, P]) Unmarshal(data []byte) (P, error) {
+	v := P(new(M))
+	if err := proto.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+var (
+	_ Codec[map[string]int] = JSON[map[string]int]{}
+	_ Codec[map[string]int] = Gob[map[string]int]{}
+	_ Codec[map[string]int] = MsgPack[map[string]int]{}
+)
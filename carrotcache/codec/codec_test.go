@@ -0,0 +1,107 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+)
+
+type user struct {
+	Name    string
+	Age     int
+	Tags    []string
+	Scores  map[string]float64
+	Avatar  []byte
+	Friend  *user
+	Secret  string `msgpack:"-" json:"-"`
+	private int
+}
+
+// roundTrip：编码后再解码，结果应当与原值相同
+func roundTrip[T any](t *testing.T, c Codec[T], v T) T {
+	t.Helper()
+	b, err := c.Marshal(v)
+	if err != nil {
+		t.Fatalf("%T marshal failed: %v", c, err)
+	}
+	got, err := c.Unmarshal(b)
+	if err != nil {
+		t.Fatalf("%T unmarshal failed: %v", c, err)
+	}
+	return got
+}
+
+func TestCodecs(t *testing.T) {
+	v := user{
+		Name:   "Tom",
+		Age:    630,
+		Tags:   []string{"a", "b"},
+		Scores: map[string]float64{"math": 99.5},
+		Avatar: []byte{1, 2, 3},
+		Friend: &user{Name: "Jack", Age: -1},
+	}
+	for _, c := range []Codec[user]{JSON[user]{}, Gob[user]{}, MsgPack[user]{}} {
+		if got := roundTrip(t, c, v); !reflect.DeepEqual(got, v) {
+			t.Fatalf("%T: expect %+v, but %+v got", c, v, got)
+		}
+	}
+
+	req := &pb.Request{Group: "scores", Key: "Tom"}
+	got := roundTrip(t, Proto[pb.Request](), req)
+	if got.GetGroup() != "scores" || got.GetKey() != "Tom" {
+		t.Fatalf("expect %v, but %v got", req, got)
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	// 与 MessagePack 规范中的编码一致
+	tests := []struct {
+		v    interface{}
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{int64(1), []byte{0x01}},
+		{int64(-1), []byte{0xff}},
+		{int64(-33), []byte{0xd0, 0xdf}},
+		{int64(256), []byte{0xcd, 0x01, 0x00}},
+		{"a", []byte{0xa1, 'a'}},
+		{[]byte{1}, []byte{0xc4, 0x01, 0x01}},
+		{[]interface{}{int64(1), "a"}, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{map[string]interface{}{"a": int64(1)}, []byte{0x81, 0xa1, 'a', 0x01}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+	}
+	c := MsgPack[interface{}]{}
+	for _, tt := range tests {
+		b, err := c.Marshal(tt.v)
+		if err != nil || !bytes.Equal(b, tt.want) {
+			t.Fatalf("marshal %v: expect %x, but %x (%v) got", tt.v, tt.want, b, err)
+		}
+		if got, err := c.Unmarshal(b); err != nil || !reflect.DeepEqual(got, tt.v) {
+			t.Fatalf("unmarshal %x: expect %v, but %v (%v) got", b, tt.v, got, err)
+		}
+	}
+
+	// 整数使用最短的格式，解码时检查是否溢出
+	ints := MsgPack[int64]{}
+	for _, n := range []int64{0, 127, 128, -32, math.MinInt8, math.MaxInt16, math.MinInt32, math.MaxInt64, math.MinInt64} {
+		if got := roundTrip[int64](t, ints, n); got != n {
+			t.Fatalf("expect %d, but %d got", n, got)
+		}
+	}
+	b, _ := ints.Marshal(300)
+	if _, err := (MsgPack[int8]{}).Unmarshal(b); err == nil {
+		t.Fatal("expect overflow error")
+	}
+	if _, err := (MsgPack[string]{}).Unmarshal([]byte{0xa5, 'a'}); err == nil {
+		t.Fatal("expect error for truncated data")
+	}
+	// 新增的字段被旧版本忽略
+	b, _ = (MsgPack[map[string]interface{}]{}).Marshal(map[string]interface{}{"Name": "Tom", "Extra": []interface{}{int64(1)}})
+	if u, err := (MsgPack[user]{}).Unmarshal(b); err != nil || u.Name != "Tom" {
+		t.Fatalf("unknown fields should be skipped, but %+v (%v) got", u, err)
+	}
+}
@@ -0,0 +1,650 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// MsgPack：紧凑的二进制编码，格式与 MessagePack 兼容，比 JSON 更小且不依赖 Go 的类型信息。
+// 支持 bool、整数、浮点数、string、[]byte、切片、数组、map、结构体、指针以及 interface{}；
+// 结构体编码为以字段名为 key 的 map，可以通过 `msgpack:"name"` 标签改名，`msgpack:"-"` 忽略该字段，未导出的字段不参与编码。
+// 解码到 interface{} 时，整数为 int64（超出范围时为 uint64），浮点数为 float64，数组为 []interface{}，map 为 map[string]interface{}。
+type MsgPack[T any] struct{}
+
+// Marshal：实现 Codec 接口
+func (MsgPack[T]) Marshal(v T) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(&v).Elem()); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal：实现 Codec 接口
+func (MsgPack[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	d := &decoder{buf: data}
+	if err := d.decode(reflect.ValueOf(&v).Elem()); err != nil {
+		return v, err
+	}
+	if d.off != len(d.buf) {
+		return v, fmt.Errorf("msgpack: %d trailing bytes", len(d.buf)-d.off)
+	}
+	return v, nil
+}
+
+// MessagePack 中使用到的类型标记
+const (
+	mpNil     = 0xc0
+	mpFalse   = 0xc2
+	mpTrue    = 0xc3
+	mpBin8    = 0xc4
+	mpBin16   = 0xc5
+	mpBin32   = 0xc6
+	mpFloat32 = 0xca
+	mpFloat64 = 0xcb
+	mpUint8   = 0xcc
+	mpUint16  = 0xcd
+	mpUint32  = 0xce
+	mpUint64  = 0xcf
+	mpInt8    = 0xd0
+	mpInt16   = 0xd1
+	mpInt32   = 0xd2
+	mpInt64   = 0xd3
+	mpStr8    = 0xd9
+	mpStr16   = 0xda
+	mpStr32   = 0xdb
+	mpArray16 = 0xdc
+	mpArray32 = 0xdd
+	mpMap16   = 0xde
+	mpMap32   = 0xdf
+
+	mpFixMap   = 0x80 // 0x80-0x8f，低 4 位为元素个数
+	mpFixArray = 0x90 // 0x90-0x9f，低 4 位为元素个数
+	mpFixStr   = 0xa0 // 0xa0-0xbf，低 5 位为字节数
+)
+
+var errShortBuffer = errors.New("msgpack: unexpected end of data")
+
+// field：结构体中参与编码的字段
+type field struct {
+	name  string
+	index int
+}
+
+// structFields：缓存每个结构体类型参与编码的字段，避免每次都通过反射解析标签
+var structFields sync.Map // map[reflect.Type][]field
+
+// fieldsOf：返回结构体类型 t 中参与编码的字段
+func fieldsOf(t reflect.Type) []field {
+	if fields, ok := structFields.Load(t); ok {
+		return fields.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	structFields.Store(t, fields)
+	return fields
+}
+
+// encoder：将任意值编码为 MessagePack 格式，结果追加到 buf 中
+type encoder struct {
+	buf []byte
+}
+
+// encode：按照 v 的类型进行编码
+func (e *encoder) encode(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		e.buf = append(e.buf, mpNil)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, mpTrue)
+		} else {
+			e.buf = append(e.buf, mpFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, mpFloat32)
+		e.buf = appendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, mpFloat64)
+		e.buf = appendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeHeader(v.Len(), mpFixStr, 31, mpStr8, mpStr16, mpStr32)
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeHeader(v.Len(), 0, -1, mpBin8, mpBin16, mpBin32)
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		e.encodeHeader(v.Len(), mpFixMap, 15, 0, mpMap16, mpMap32)
+		for it := v.MapRange(); it.Next(); {
+			if err := e.encode(it.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(it.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		e.encodeHeader(len(fields), mpFixMap, 15, 0, mpMap16, mpMap32)
+		for _, f := range fields {
+			e.encodeHeader(len(f.name), mpFixStr, 31, mpStr8, mpStr16, mpStr32)
+			e.buf = append(e.buf, f.name...)
+			if err := e.encode(v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// encodeArray：编码切片或数组中的每个元素
+func (e *encoder) encodeArray(v reflect.Value) error {
+	e.encodeHeader(v.Len(), mpFixArray, 15, 0, mpArray16, mpArray32)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeHeader：写入长度为 n 的类型标记，n 不超过 fixMax 时使用 fix 格式，标记为 0 表示不存在对应的格式
+func (e *encoder) encodeHeader(n int, fix byte, fixMax int, t8, t16, t32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint8 && t8 != 0:
+		e.buf = append(e.buf, t8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, t16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, t32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+// encodeInt：使用能够表示 n 的最短格式编码有符号整数
+func (e *encoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, mpInt8, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, mpInt16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, mpInt32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, mpInt64)
+		e.buf = appendUint64(e.buf, uint64(n))
+	}
+}
+
+// encodeUint：使用能够表示 n 的最短格式编码无符号整数
+func (e *encoder) encodeUint(n uint64) {
+	switch {
+	case n <= 127:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, mpUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, mpUint16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, mpUint32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, mpUint64)
+		e.buf = appendUint64(e.buf, n)
+	}
+}
+
+// decoder：从 buf 的 off 处开始解码 MessagePack 格式的数据
+type decoder struct {
+	buf []byte
+	off int
+}
+
+// next：读取接下来的 n 个字节
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.off < n {
+		return nil, errShortBuffer
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// peek：返回下一个类型标记但不移动 off
+func (d *decoder) peek() (byte, error) {
+	if d.off >= len(d.buf) {
+		return 0, errShortBuffer
+	}
+	return d.buf[d.off], nil
+}
+
+// readUint：读取 n 个字节的大端无符号整数
+func (d *decoder) readUint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// decode：将下一个值解码到 v 中，nil 会将 v 设置为零值
+func (d *decoder) decode(v reflect.Value) error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if c == mpNil {
+		d.off++
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack: cannot decode into non-empty interface %s", v.Type())
+		}
+		x, err := d.decodeAny()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(x))
+	case reflect.Bool:
+		d.off++
+		switch c {
+		case mpTrue:
+			v.SetBool(true)
+		case mpFalse:
+			v.SetBool(false)
+		default:
+			return fmt.Errorf("msgpack: cannot decode 0x%02x into %s", c, v.Type())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := d.decodeNumber()
+		if err != nil {
+			return err
+		}
+		var n int64
+		switch x := x.(type) {
+		case int64:
+			n = x
+		case uint64:
+			if x > math.MaxInt64 {
+				return fmt.Errorf("msgpack: %d overflows %s", x, v.Type())
+			}
+			n = int64(x)
+		default:
+			return fmt.Errorf("msgpack: cannot decode %T into %s", x, v.Type())
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, err := d.decodeNumber()
+		if err != nil {
+			return err
+		}
+		var n uint64
+		switch x := x.(type) {
+		case int64:
+			if x < 0 {
+				return fmt.Errorf("msgpack: %d overflows %s", x, v.Type())
+			}
+			n = uint64(x)
+		case uint64:
+			n = x
+		default:
+			return fmt.Errorf("msgpack: cannot decode %T into %s", x, v.Type())
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x, err := d.decodeNumber()
+		if err != nil {
+			return err
+		}
+		switch x := x.(type) {
+		case float64:
+			v.SetFloat(x)
+		case int64:
+			v.SetFloat(float64(x))
+		case uint64:
+			v.SetFloat(float64(x))
+		}
+	case reflect.String:
+		b, err := d.decodeBytes()
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.decodeBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		n, err := d.decodeArrayLen()
+		if err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			if err := d.decode(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		n, err := d.decodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n > v.Len() {
+			return fmt.Errorf("msgpack: %d elements overflow %s", n, v.Type())
+		}
+		v.Set(reflect.Zero(v.Type()))
+		for i := 0; i < n; i++ {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n, err := d.decodeMapLen()
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := d.decode(key); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		n, err := d.decodeMapLen()
+		if err != nil {
+			return err
+		}
+		fields := fieldsOf(v.Type())
+		for i := 0; i < n; i++ {
+			name, err := d.decodeBytes()
+			if err != nil {
+				return err
+			}
+			index := -1
+			for _, f := range fields {
+				if f.name == string(name) {
+					index = f.index
+					break
+				}
+			}
+			// 未知的字段直接跳过，便于增删字段
+			if index < 0 {
+				if _, err := d.decodeAny(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(v.Field(index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// decodeAny：解码下一个值，类型由数据本身决定
+func (d *decoder) decodeAny() (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == mpNil:
+		d.off++
+		return nil, nil
+	case c == mpTrue || c == mpFalse:
+		d.off++
+		return c == mpTrue, nil
+	case c <= 0x7f || c >= 0xe0 || (c >= mpFloat32 && c <= mpInt64):
+		return d.decodeNumber()
+	case c&0xe0 == mpFixStr || (c >= mpStr8 && c <= mpStr32):
+		b, err := d.decodeBytes()
+		return string(b), err
+	case c >= mpBin8 && c <= mpBin32:
+		b, err := d.decodeBytes()
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case c&0xf0 == mpFixArray || c == mpArray16 || c == mpArray32:
+		n, err := d.decodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		s := make([]interface{}, n)
+		for i := range s {
+			if s[i], err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case c&0xf0 == mpFixMap || c == mpMap16 || c == mpMap32:
+		n, err := d.decodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := d.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("msgpack: unsupported map key %T", key)
+			}
+			if m[s], err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+// decodeNumber：解码整数或浮点数，返回 int64、uint64 或 float64
+func (d *decoder) decodeNumber() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	}
+	switch c {
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		u, err := d.readUint(1 << (c - mpUint8))
+		if err != nil {
+			return nil, err
+		}
+		if u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return u, nil
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		size := 1 << (c - mpInt8)
+		u, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// 按照字节数进行符号扩展
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, nil
+	case mpFloat32:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case mpFloat64:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	}
+	return nil, fmt.Errorf("msgpack: 0x%02x is not a number", c)
+}
+
+// decodeBytes：解码 str 或 bin，返回的切片引用 buf，调用方需要自行拷贝
+func (d *decoder) decodeBytes() ([]byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	var n uint64
+	switch c := b[0]; {
+	case c&0xe0 == mpFixStr:
+		n = uint64(c & 0x1f)
+	case c == mpStr8 || c == mpBin8:
+		n, err = d.readUint(1)
+	case c == mpStr16 || c == mpBin16:
+		n, err = d.readUint(2)
+	case c == mpStr32 || c == mpBin32:
+		n, err = d.readUint(4)
+	default:
+		return nil, fmt.Errorf("msgpack: 0x%02x is not a string", c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(n))
+}
+
+// decodeArrayLen：解码数组的元素个数
+func (d *decoder) decodeArrayLen() (int, error) {
+	return d.decodeLen(mpFixArray, mpArray16, mpArray32)
+}
+
+// decodeMapLen：解码 map 的键值对个数
+func (d *decoder) decodeMapLen() (int, error) {
+	return d.decodeLen(mpFixMap, mpMap16, mpMap32)
+}
+
+// decodeLen：解码 fix、16 位或 32 位格式的长度
+func (d *decoder) decodeLen(fix, t16, t32 byte) (int, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch c := b[0]; {
+	case c&0xf0 == fix:
+		n = uint64(c & 0x0f)
+	case c == t16:
+		n, err = d.readUint(2)
+	case c == t32:
+		n, err = d.readUint(4)
+	default:
+		return 0, fmt.Errorf("msgpack: unexpected type 0x%02x", c)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// 每个元素至少占用 1 个字节，避免被损坏的数据申请过大的内存
+	if n > uint64(len(d.buf)-d.off) {
+		return 0, errShortBuffer
+	}
+	return int(n), nil
+}
+
+// appendUint16：以大端序追加 16 位无符号整数
+func appendUint16(b []byte, n uint16) []byte {
+	return append(b, byte(n>>8), byte(n))
+}
+
+// appendUint32：以大端序追加 32 位无符号整数
+func appendUint32(b []byte, n uint32) []byte {
+	return append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// appendUint64：以大端序追加 64 位无符号整数
+func appendUint64(b []byte, n uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(n>>32)), uint32(n))
+}
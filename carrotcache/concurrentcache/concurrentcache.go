@@ -1,41 +1,374 @@
 package concurrentcache
 
 import (
+	"github.com/Dongxiem/carrotCache/carrotcache/arc"
+	"github.com/Dongxiem/carrotCache/carrotcache/arena"
 	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/clock"
+	"github.com/Dongxiem/carrotCache/carrotcache/counter"
+	"github.com/Dongxiem/carrotCache/carrotcache/fifo"
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+	"github.com/Dongxiem/carrotCache/carrotcache/lfu"
 	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+	"github.com/Dongxiem/carrotCache/carrotcache/tinylfu"
+	"github.com/Dongxiem/carrotCache/carrotcache/twoq"
 	"sync"
+	"time"
 )
 
-// cache：主要添加互斥锁来进行并发控制
+// Store：Cache 的底层存储，由 Cache 负责加锁，实现时无需考虑并发。
+// 实现需要按照 len(key)+value.Len() 及每条数据的额外开销（链表节点、map 等，参见 lru.EntryOverhead）统计内存，并在超出上限时自动淘汰，淘汰、过期及主动删除的数据都要调用 onEvicted
+type Store interface {
+	AddWithExpire(key string, value lru.Value, expire time.Time)
+	Get(key string) (value lru.Value, ok bool)
+	// Peek 与 Get 相同，但不能修改任何内部状态（包括淘汰顺序、访问频率及惰性删除），以便在读锁下并发调用
+	Peek(key string) (value lru.Value, ok bool)
+	Remove(key string)
+	RemoveOldest()
+	RemoveExpired() int
+	Len() int
+	Bytes() int64
+	// SetMaxBytes 修改内存上限，超出新的上限时需要立即淘汰
+	SetMaxBytes(maxBytes int64)
+}
+
+// EvictionPolicy：根据内存上限及淘汰回调函数创建底层存储，决定了 Cache 的淘汰策略
+type EvictionPolicy func(maxBytes int64, onEvicted func(key string, value lru.Value)) Store
+
+var (
+	// LRU：最近最少使用，默认的淘汰策略
+	LRU EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return lru.New(maxBytes, onEvicted)
+	}
+	// TinyLFU：W-TinyLFU，适合存在大量一次性访问（扫描）的场景
+	TinyLFU EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return tinylfu.New(maxBytes, onEvicted)
+	}
+	// ARC：自适应替换缓存，根据访问模式在最近性与频率之间自动调整
+	ARC EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return arc.New(maxBytes, onEvicted)
+	}
+	// Arena：将数据保存在不含指针的大字节数组中，GC 开销与数据条数无关，适合缓存大量数据，按照写入顺序淘汰
+	Arena EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return arena.New(maxBytes, onEvicted)
+	}
+	// LFU：最不经常使用，访问次数相同时淘汰最久未访问的数据
+	LFU EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return lfu.New(maxBytes, onEvicted)
+	}
+	// TwoQ：2Q，只被访问一次的数据不会进入主队列
+	TwoQ EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return twoq.New(maxBytes, onEvicted)
+	}
+	// FIFO：先进先出，访问不会改变淘汰顺序
+	FIFO EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return fifo.New(maxBytes, onEvicted)
+	}
+	// CLOCK：二次机会，命中时只设置访问位，开销比 LRU 更低
+	CLOCK EvictionPolicy = func(maxBytes int64, onEvicted func(string, lru.Value)) Store {
+		return clock.New(maxBytes, onEvicted)
+	}
+)
+
+// cache：主要添加互斥锁来进行并发控制。
+// Shards 大于 1 时，按照 key 的哈希将数据分散到多个分片中，每个分片拥有独立的锁及 CacheBytes/Shards 的内存，
+// 以减少多核下的锁竞争，代价是淘汰只在分片内进行，整体上是近似的。
+// BufferedReads 为 true 时，Get 只在读锁下调用 Store.Peek，命中记录到有损的读缓冲区中，
+// 由后台协程批量回放以更新淘汰顺序，命中路径不再需要互斥锁，代价是淘汰顺序是近似的，过期数据也改由回放或清理协程移除。
+// CacheBytes 为 0 表示不限制内存，第一次使用之后只能通过 SetCacheBytes 修改。
 type Cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
-	CacheBytes int64
+	bytes         counter.AtomicInt // 所有分片已使用内存之和，放在开头以保证 64 位对齐
+	CacheBytes    int64
+	Policy        EvictionPolicy // 淘汰策略，为 nil 时使用 LRU
+	Shards        int            // 分片数量，为 0 或 1 时不分片，需在第一次使用前设置
+	BufferedReads bool           // 是否使用读缓冲区异步更新淘汰顺序，需在第一次使用前设置
+	once          sync.Once      // 懒加载分片
+	shards        []*shard
+	newStore      func(s *shard) Store // 为分片创建底层存储，由 init 设置
+	reads         *readBuffer          // 读缓冲区，BufferedReads 为 false 时为 nil
+	mu            sync.Mutex           // 保护 stop 与 closed
+	stop          chan struct{}        // 用于通知后台清理协程退出，为 nil 表示清理协程未启动
+	closed        bool                 // 是否已经调用 Close
+}
+
+// shard：一个分片，底层存储由分片的锁保护
+type shard struct {
+	nget   counter.AtomicInt // Get 的调用次数，放在开头以保证 64 位对齐
+	nhit   counter.AtomicInt // Get 的命中次数
+	nevict counter.AtomicInt // 被移除的数据条数，包括淘汰、过期和主动删除
+	bytes  counter.AtomicInt // 已使用的内存，在持有 mu 修改底层存储后同步更新，读取时无需加锁
+	mu     sync.RWMutex
+	limit  int64 // 分片的内存上限，由 mu 保护
+	lru    Store // 懒加载，第一次写入时创建，为 nil 表示分片中没有数据
+}
+
+// CacheStats：缓存的统计信息
+type CacheStats struct {
+	Bytes     int64 // 当前已使用的内存
+	Items     int64 // 当前缓存的数据条数
+	Gets      int64 // Get 的调用次数
+	Hits      int64 // Get 的命中次数
+	Evictions int64 // 被移除的数据条数
 }
 
 // add：键值对添加
 func (c *Cache) Add(key string, value byteview.ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	// 懒加载，进行实例化 lru
-	// 一个对象的延迟初始化意味着该对象的创建将会延迟至第一次使用该对象时。主要用于提高性能，并减少程序内存要求
-	if c.lru == nil {
-		c.lru = lru.New(c.CacheBytes, nil)
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：键值对添加，并设置过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value byteview.ByteView, expire time.Time) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lru == nil {
+		s.lru = c.newStore(s)
 	}
-	// 已经实例化了之后将数据进行添加进 lru
-	c.lru.Add(key, value)
+	before := s.lru.Bytes()
+	s.lru.AddWithExpire(key, value, expire)
+	c.track(s, before)
 }
 
 // get：根据键得到值
 func (c *Cache) Get(key string) (value byteview.ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		return
+	s := c.shard(key)
+	s.nget.Add(1)
+	var v lru.Value
+	if c.reads != nil {
+		// 读缓冲模式：只读查找，访问记录交给后台协程回放，未命中也记录以便 TinyLFU 等策略统计频率
+		s.mu.RLock()
+		if s.lru != nil {
+			v, ok = s.lru.Peek(key)
+		}
+		s.mu.RUnlock()
+		c.reads.push(key)
+	} else {
+		s.mu.Lock()
+		if s.lru != nil {
+			// Get 可能惰性删除过期数据
+			before := s.lru.Bytes()
+			v, ok = s.lru.Get(key)
+			c.track(s, before)
+		}
+		s.mu.Unlock()
 	}
 	// 去 lru 当中找，找到则返回 ByteView 的只读数据
-	if v, ok := c.lru.Get(key); ok {
+	if ok {
+		s.nhit.Add(1)
 		return v.(byteview.ByteView), ok
 	}
 	return
 }
+
+// Stats：返回缓存统计信息的快照，分片时为所有分片之和
+func (c *Cache) Stats() CacheStats {
+	c.init()
+	var stats CacheStats
+	for _, s := range c.shards {
+		stats.Gets += s.nget.Get()
+		stats.Hits += s.nhit.Get()
+		stats.Evictions += s.nevict.Get()
+		stats.Bytes += s.bytes.Get()
+		s.mu.RLock()
+		if s.lru != nil {
+			stats.Items += int64(s.lru.Len())
+		}
+		s.mu.RUnlock()
+	}
+	return stats
+}
+
+// Bytes：返回当前已使用的内存，包括每条数据的额外开销。只读取原子计数，不对分片加锁
+func (c *Cache) Bytes() int64 {
+	return c.bytes.Get()
+}
+
+// RemoveOldest：按照淘汰策略淘汰一条数据，分片时从使用内存最多的分片中淘汰，只对该分片加锁
+func (c *Cache) RemoveOldest() {
+	c.init()
+	var victim *shard
+	var most int64
+	for _, s := range c.shards {
+		if n := s.bytes.Get(); n > most {
+			victim, most = s, n
+		}
+	}
+	if victim != nil {
+		victim.mu.Lock()
+		before := victim.lru.Bytes()
+		victim.lru.RemoveOldest()
+		c.track(victim, before)
+		victim.mu.Unlock()
+	}
+}
+
+// SetCacheBytes：在使用过程中修改内存上限，每个分片立即按照新的上限淘汰，之后创建的底层存储同样使用新的上限。
+// 调用方需保证 SetCacheBytes 不会并发调用
+func (c *Cache) SetCacheBytes(cacheBytes int64) {
+	c.init()
+	c.CacheBytes = cacheBytes
+	perShard := shardBytes(cacheBytes, len(c.shards))
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.limit = perShard
+		if s.lru != nil {
+			before := s.lru.Bytes()
+			s.lru.SetMaxBytes(perShard)
+			c.track(s, before)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Remove：根据键移除对应的数据
+func (c *Cache) Remove(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lru != nil {
+		before := s.lru.Bytes()
+		s.lru.Remove(key)
+		c.track(s, before)
+	}
+}
+
+// RemoveExpired：清理所有已经过期的数据，返回清理的数量
+func (c *Cache) RemoveExpired() int {
+	c.init()
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if s.lru != nil {
+			before := s.lru.Bytes()
+			n += s.lru.RemoveExpired()
+			c.track(s, before)
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// StartSweeper：启动后台清理协程，每隔 interval 清理一次过期数据，重复调用或 Close 之后调用无效
+func (c *Cache) StartSweeper(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil || c.closed || interval <= 0 {
+		return
+	}
+	c.stop = make(chan struct{})
+	go c.sweep(interval, c.stop)
+}
+
+// StopSweeper：停止后台清理协程
+func (c *Cache) StopSweeper() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// Close：停止后台清理协程及读缓冲区的回放协程，重复调用无效。Close 之后仍可读写，但不再有后台维护
+func (c *Cache) Close() {
+	c.StopSweeper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	// 读缓冲区在第一次使用时创建，这里确保之后不会再启动回放协程
+	c.init()
+	if c.reads != nil {
+		c.reads.close()
+	}
+}
+
+// sweep：后台清理协程的主循环，过期数据在 Get 时会被惰性删除，这里负责回收长时间无人访问的过期数据
+func (c *Cache) sweep(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.RemoveExpired()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// init：懒加载，创建分片，每个分片的底层存储在第一次写入时才根据淘汰策略实例化，只读取的分片不会分配存储
+// 一个对象的延迟初始化意味着该对象的创建将会延迟至第一次使用该对象时。主要用于提高性能，并减少程序内存要求
+func (c *Cache) init() {
+	c.once.Do(func() {
+		policy := c.Policy
+		if policy == nil {
+			policy = LRU
+		}
+		n := c.Shards
+		if n < 1 {
+			n = 1
+		}
+		perShard := shardBytes(c.CacheBytes, n)
+		c.newStore = func(s *shard) Store {
+			return policy(s.limit, func(key string, value lru.Value) {
+				s.nevict.Add(1)
+			})
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			c.shards[i] = &shard{limit: perShard}
+		}
+		if c.BufferedReads {
+			c.reads = newReadBuffer(c.replay)
+		}
+	})
+}
+
+// shardBytes：每个分片平分内存，内存有上限时每个分片至少 1 字节，避免被当作不限制
+func shardBytes(cacheBytes int64, n int) int64 {
+	perShard := cacheBytes / int64(n)
+	if cacheBytes > 0 && perShard == 0 {
+		perShard = 1
+	}
+	return perShard
+}
+
+// replay：回放读缓冲区中的访问记录，调用 Store.Get 更新淘汰顺序，每个分片只加一次锁
+func (c *Cache) replay(keys []string) {
+	byShard := make(map[*shard][]string)
+	for _, key := range keys {
+		s := c.shard(key)
+		byShard[s] = append(byShard[s], key)
+	}
+	for s, keys := range byShard {
+		s.mu.Lock()
+		if s.lru != nil {
+			before := s.lru.Bytes()
+			for _, key := range keys {
+				s.lru.Get(key)
+			}
+			c.track(s, before)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// track：底层存储修改后，将已使用内存的变化同步到分片及 Cache 的计数中，调用方需持有 s.mu
+func (c *Cache) track(s *shard, before int64) {
+	if delta := s.lru.Bytes() - before; delta != 0 {
+		s.bytes.Add(delta)
+		c.bytes.Add(delta)
+	}
+}
+
+// shard：返回 key 所在的分片
+func (c *Cache) shard(key string) *shard {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[fnvhash.Sum32(key)%uint32(len(c.shards))]
+}
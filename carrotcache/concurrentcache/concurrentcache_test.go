@@ -0,0 +1,235 @@
+package concurrentcache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// TestCache_Shards：测试分片平分内存，统计信息为所有分片之和
+func TestCache_Shards(t *testing.T) {
+	c := &Cache{CacheBytes: 1 << 14, Shards: 8}
+	for i := 0; i < 1000; i++ {
+		c.Add(fmt.Sprintf("key%03d", i), bv("value"))
+	}
+	if len(c.shards) != 8 {
+		t.Fatalf("got %d shards, want 8", len(c.shards))
+	}
+	for i, s := range c.shards {
+		if s.lru.Bytes() > 1<<14/8 {
+			t.Fatalf("shard %d uses %d bytes, exceeds %d", i, s.lru.Bytes(), 1<<14/8)
+		}
+	}
+	hits := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		if v, ok := c.Get(key); ok {
+			if v.String() != "value" {
+				t.Fatalf("got %s for %s", v.String(), key)
+			}
+			hits++
+		}
+	}
+	stats := c.Stats()
+	if stats.Items+stats.Evictions != 1000 || stats.Gets != 1000 || stats.Hits != int64(hits) {
+		t.Fatalf("unexpected stats %+v, hits %d", stats, hits)
+	}
+	c.Remove("key999")
+	if _, ok := c.Get("key999"); ok {
+		t.Fatalf("key999 should be removed")
+	}
+}
+
+// TestCache_BufferedReads：测试读缓冲模式下的读取与统计，以及回放访问记录后淘汰顺序被更新
+func TestCache_BufferedReads(t *testing.T) {
+	c := &Cache{CacheBytes: 2 * entryBytes(LRU, "key1", "v1"), BufferedReads: true}
+	c.Add("key1", bv("v1"))
+	c.Add("key2", bv("v2"))
+	if v, ok := c.Get("key1"); !ok || v.String() != "v1" {
+		t.Fatalf("cache hit key1 = v1 failed")
+	}
+	if _, ok := c.Get("key3"); ok {
+		t.Fatalf("cache miss key3 failed")
+	}
+	if stats := c.Stats(); stats.Gets != 2 || stats.Hits != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	// 回放 key1 的访问后，key2 成为最久未访问的数据
+	c.replay([]string{"key1"})
+	c.Add("key3", bv("v3"))
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("key2 should be evicted")
+	}
+	if _, ok := c.Get("key1"); !ok {
+		t.Fatalf("key1 should survive")
+	}
+}
+
+// TestReadBuffer：测试条带写满后交给回放协程，回放协程积压时丢弃
+func TestReadBuffer(t *testing.T) {
+	replayed := make(chan []string)
+	b := newReadBuffer(func(keys []string) { replayed <- keys })
+	// sync.Pool 可能丢弃条带，这里直接操作条带
+	s := b.pool.Get().(*readStripe)
+	for i := 0; i < readStripeSize; i++ {
+		s.add(strconv.Itoa(i), b.batches)
+	}
+	select {
+	case keys := <-replayed:
+		if len(keys) != readStripeSize || keys[0] != "0" {
+			t.Fatalf("unexpected batch %v", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no batch replayed")
+	}
+	if len(s.keys) != 0 {
+		t.Fatalf("stripe should be reset, got %d keys", len(s.keys))
+	}
+	// 回放协程阻塞在 replayed 上，填满 batches 后继续写入的条带被丢弃
+	for i := 0; i < readStripeSize*(readBufferBatches+2); i++ {
+		s.add("key", b.batches)
+	}
+	if len(b.batches) != readBufferBatches || len(s.keys) != 0 {
+		t.Fatalf("got %d pending batches, want %d", len(b.batches), readBufferBatches)
+	}
+}
+
+// TestCache_LazyStore：测试只读取的分片不会创建底层存储
+func TestCache_LazyStore(t *testing.T) {
+	c := &Cache{CacheBytes: 1 << 14, Shards: 8}
+	if _, ok := c.Get("key"); ok {
+		t.Fatalf("empty cache should miss")
+	}
+	for i, s := range c.shards {
+		if s.lru != nil {
+			t.Fatalf("shard %d created a store on Get", i)
+		}
+	}
+	c.Add("key", bv("value"))
+	if v, ok := c.Get("key"); !ok || v.String() != "value" {
+		t.Fatalf("got %v %v, want value", v, ok)
+	}
+	if stats := c.Stats(); stats.Items != 1 {
+		t.Fatalf("got %d items, want 1", stats.Items)
+	}
+}
+
+// TestCache_Bytes：测试原子维护的内存计数与各分片底层存储的实际值一致
+func TestCache_Bytes(t *testing.T) {
+	c := &Cache{CacheBytes: 1 << 12, Shards: 4}
+	expire := time.Now().Add(time.Millisecond)
+	for i := 0; i < 200; i++ {
+		key := strconv.Itoa(i % 50)
+		switch i % 4 {
+		case 0:
+			c.AddWithExpire(key, bv("value"), expire)
+		case 1:
+			c.Remove(key)
+		default:
+			c.Add(key, bv(strconv.Itoa(i)))
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	c.RemoveExpired()
+	c.RemoveOldest()
+	var want int64
+	for _, s := range c.shards {
+		if s.lru != nil {
+			want += s.lru.Bytes()
+			if s.bytes.Get() != s.lru.Bytes() {
+				t.Fatalf("shard counts %d bytes, store uses %d", s.bytes.Get(), s.lru.Bytes())
+			}
+		}
+	}
+	if got := c.Bytes(); got != want || c.Stats().Bytes != want {
+		t.Fatalf("got %d bytes, want %d", got, want)
+	}
+}
+
+// benchCache：基准测试使用的缓存接口
+type benchCache interface {
+	Add(key string, value byteview.ByteView)
+	Get(key string) (byteview.ByteView, bool)
+}
+
+// mutexCache：分片之前的实现，所有操作共用一把互斥锁，作为基准测试的对照组
+type mutexCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+}
+
+func (c *mutexCache) Add(key string, value byteview.ByteView) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Add(key, value)
+}
+
+func (c *mutexCache) Get(key string) (byteview.ByteView, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.lru.Get(key); ok {
+		return v.(byteview.ByteView), true
+	}
+	return byteview.ByteView{}, false
+}
+
+// benchmarkCache：并发执行 Get，每 writeEvery 次操作执行一次 Add
+func benchmarkCache(b *testing.B, c benchCache, writeEvery int) {
+	const keys = 1 << 14
+	all := make([]string, keys)
+	for i := range all {
+		all[i] = strconv.Itoa(i)
+		c.Add(all[i], bv("value"))
+	}
+	var seed int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&seed, 1)) * 7919
+		for pb.Next() {
+			key := all[i%keys]
+			if i%writeEvery == 0 {
+				c.Add(key, bv("value"))
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+// benchmarkCaches：分别以单锁实现、不分片、分片及读缓冲模式运行 bench
+func benchmarkCaches(b *testing.B, bench func(b *testing.B, c benchCache)) {
+	const cacheBytes = 1 << 20
+	b.Run("mutex", func(b *testing.B) {
+		bench(b, &mutexCache{lru: lru.New(cacheBytes, nil)})
+	})
+	for _, shards := range []int{1, 32} {
+		for _, buffered := range []bool{false, true} {
+			b.Run(fmt.Sprintf("shards=%d/buffered=%v", shards, buffered), func(b *testing.B) {
+				c := &Cache{CacheBytes: cacheBytes, Shards: shards, BufferedReads: buffered}
+				defer c.Close()
+				bench(b, c)
+			})
+		}
+	}
+}
+
+// BenchmarkCache_Get：与分片之前的单锁实现比较并发 Get 的性能
+func BenchmarkCache_Get(b *testing.B) {
+	benchmarkCaches(b, func(b *testing.B, c benchCache) {
+		benchmarkCache(b, c, 1<<30)
+	})
+}
+
+// BenchmarkCache_GetAdd：与分片之前的单锁实现比较并发 Get/Add（读写比 9:1）的性能
+func BenchmarkCache_GetAdd(b *testing.B) {
+	benchmarkCaches(b, func(b *testing.B, c benchCache) {
+		benchmarkCache(b, c, 10)
+	})
+}
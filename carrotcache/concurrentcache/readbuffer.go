@@ -0,0 +1,67 @@
+package concurrentcache
+
+import "sync"
+
+const (
+	readStripeSize    = 64 // 每个条带缓冲的 key 数量，写满后整体交给回放协程
+	readBufferBatches = 16 // 等待回放的条带数量上限，超出时直接丢弃
+)
+
+// readBuffer：有损的读缓冲区，记录命中的 key，由后台协程异步批量回放以更新淘汰顺序。
+// 条带放在 sync.Pool 中，各个 P 通常拿到不同的条带，因此记录访问几乎无需竞争；
+// 回放协程忙不过来或条带被 GC 回收时，部分访问记录会被丢弃，淘汰顺序因此只是近似的 LRU。
+type readBuffer struct {
+	pool    sync.Pool
+	batches chan []string
+	stop    chan struct{} // 关闭后回放协程退出
+}
+
+// readStripe：一个条带
+type readStripe struct {
+	keys []string
+}
+
+// newReadBuffer：创建读缓冲区，并启动后台协程调用 replay 回放写满的条带
+func newReadBuffer(replay func(keys []string)) *readBuffer {
+	b := &readBuffer{batches: make(chan []string, readBufferBatches), stop: make(chan struct{})}
+	b.pool.New = func() interface{} {
+		return &readStripe{keys: make([]string, 0, readStripeSize)}
+	}
+	go func() {
+		for {
+			select {
+			case keys := <-b.batches:
+				replay(keys)
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+	return b
+}
+
+// close：停止回放协程，之后记录的访问在条带写满时直接丢弃
+func (b *readBuffer) close() {
+	close(b.stop)
+}
+
+// push：记录一次访问
+func (b *readBuffer) push(key string) {
+	s := b.pool.Get().(*readStripe)
+	s.add(key, b.batches)
+	b.pool.Put(s)
+}
+
+// add：将 key 添加到条带中，条带写满时尝试交给回放协程，回放协程积压时丢弃整个条带
+func (s *readStripe) add(key string, batches chan<- []string) {
+	s.keys = append(s.keys, key)
+	if len(s.keys) < readStripeSize {
+		return
+	}
+	select {
+	case batches <- s.keys:
+		s.keys = make([]string, 0, readStripeSize)
+	default:
+		s.keys = s.keys[:0]
+	}
+}
@@ -0,0 +1,217 @@
+package concurrentcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// policyCase：一致性测试中的一个淘汰策略
+type policyCase struct {
+	policy       EvictionPolicy
+	countsGhosts bool // Bytes 还包括只保存 key 的幽灵节点，因此可能大于有效数据所占用的内存
+}
+
+// policies：所有预置的淘汰策略，新增的策略需要加入这里并通过以下的一致性测试
+var policies = map[string]policyCase{
+	"LRU":     {policy: LRU},
+	"TinyLFU": {policy: TinyLFU},
+	"ARC":     {policy: ARC, countsGhosts: true},
+	"Arena":   {policy: Arena},
+	"LFU":     {policy: LFU},
+	"TwoQ":    {policy: TwoQ, countsGhosts: true},
+	"FIFO":    {policy: FIFO},
+	"CLOCK":   {policy: CLOCK},
+}
+
+// forEachPolicy：对每个淘汰策略运行一次子测试
+func forEachPolicy(t *testing.T, f func(t *testing.T, policy EvictionPolicy)) {
+	forEachPolicyCase(t, func(t *testing.T, c policyCase) { f(t, c.policy) })
+}
+
+// forEachPolicyCase：与 forEachPolicy 相同，但传入策略的完整描述
+func forEachPolicyCase(t *testing.T, f func(t *testing.T, c policyCase)) {
+	for name, c := range policies {
+		c := c
+		t.Run(name, func(t *testing.T) { f(t, c) })
+	}
+}
+
+func bv(s string) byteview.ByteView {
+	return byteview.ByteView{B: []byte(s)}
+}
+
+// entryBytes：一条数据在 policy 创建的存储中所占用的内存，包括额外开销
+func entryBytes(policy EvictionPolicy, key, value string) int64 {
+	s := policy(0, nil)
+	s.AddWithExpire(key, bv(value), time.Time{})
+	return s.Bytes()
+}
+
+// TestStore_GetAdd：测试添加、获取、修改及内存统计
+func TestStore_GetAdd(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		s := policy(0, nil)
+		s.AddWithExpire("key1", bv("1234"), time.Time{})
+		if v, ok := s.Get("key1"); !ok || v.(byteview.ByteView).String() != "1234" {
+			t.Fatalf("cache hit key1 = 1234 failed")
+		}
+		if _, ok := s.Get("key2"); ok {
+			t.Fatalf("cache miss key2 failed")
+		}
+		s.AddWithExpire("key1", bv("1"), time.Time{})
+		if v, ok := s.Get("key1"); !ok || v.(byteview.ByteView).String() != "1" {
+			t.Fatalf("update key1 failed")
+		}
+		if s.Len() != 1 || s.Bytes() != entryBytes(policy, "key1", "1") {
+			t.Fatalf("got len %d bytes %d after update", s.Len(), s.Bytes())
+		}
+	})
+}
+
+// TestStore_Peek：测试 Peek 能读到未过期的数据，且不会惰性删除过期数据
+func TestStore_Peek(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		s := policy(0, nil)
+		s.AddWithExpire("key1", bv("v1"), time.Time{})
+		s.AddWithExpire("key2", bv("v2"), time.Now().Add(-time.Second))
+		if v, ok := s.Peek("key1"); !ok || v.(byteview.ByteView).String() != "v1" {
+			t.Fatalf("peek key1 failed")
+		}
+		if _, ok := s.Peek("key2"); ok {
+			t.Fatalf("expired key2 should miss")
+		}
+		if _, ok := s.Peek("key3"); ok {
+			t.Fatalf("peek miss key3 failed")
+		}
+		if s.Len() != 2 {
+			t.Fatalf("Peek should not remove entries, got len %d", s.Len())
+		}
+	})
+}
+
+// TestStore_Remove：测试主动删除，删除的数据也需要调用回调函数
+func TestStore_Remove(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		var evicted []string
+		s := policy(0, func(key string, value lru.Value) { evicted = append(evicted, key) })
+		s.AddWithExpire("key1", bv("v1"), time.Time{})
+		s.AddWithExpire("key2", bv("v2"), time.Time{})
+		s.Remove("key1")
+		s.Remove("missing")
+		if _, ok := s.Get("key1"); ok {
+			t.Fatalf("key1 should be removed")
+		}
+		if s.Len() != 1 || s.Bytes() != entryBytes(policy, "key2", "v2") {
+			t.Fatalf("got len %d bytes %d after remove", s.Len(), s.Bytes())
+		}
+		if len(evicted) != 1 || evicted[0] != "key1" {
+			t.Fatalf("got evicted %v, want [key1]", evicted)
+		}
+	})
+}
+
+// TestStore_Expire：测试过期数据视为未命中，并能被 RemoveExpired 清理
+func TestStore_Expire(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		s := policy(0, nil)
+		past, future := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+		s.AddWithExpire("key1", bv("v1"), past)
+		s.AddWithExpire("key2", bv("v2"), past)
+		s.AddWithExpire("key3", bv("v3"), future)
+		if _, ok := s.Get("key1"); ok {
+			t.Fatalf("expired key1 should miss")
+		}
+		if n := s.RemoveExpired(); n != 1 {
+			t.Fatalf("RemoveExpired removed %d entries, want 1", n)
+		}
+		if _, ok := s.Get("key3"); !ok || s.Len() != 1 {
+			t.Fatalf("key3 should survive, len %d", s.Len())
+		}
+	})
+}
+
+// TestStore_Bounded：测试内存不会超过上限，且每条被淘汰的数据都调用了回调函数
+func TestStore_Bounded(t *testing.T) {
+	forEachPolicyCase(t, func(t *testing.T, c policyCase) {
+		const maxBytes = 1 << 12
+		evicted := 0
+		s := c.policy(maxBytes, func(key string, value lru.Value) { evicted++ })
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key%03d", i)
+			s.AddWithExpire(key, bv("value"), time.Time{})
+			s.Get(fmt.Sprintf("key%03d", i/2))
+			if s.Bytes() > maxBytes {
+				t.Fatalf("bytes %d exceed limit %d", s.Bytes(), maxBytes)
+			}
+		}
+		if s.Len()+evicted != 1000 {
+			t.Fatalf("len %d + evicted %d != 1000", s.Len(), evicted)
+		}
+		// 保存幽灵节点的策略，Bytes 还包括幽灵 key 所占用的内存
+		data := int64(s.Len()) * entryBytes(c.policy, "key000", "value")
+		if got := s.Bytes(); got != data && !(c.countsGhosts && got > data) {
+			t.Fatalf("bytes %d do not match len %d", got, s.Len())
+		}
+	})
+}
+
+// TestStore_SetMaxBytes：测试缩小内存上限时立即淘汰，扩大之后可以继续写入
+func TestStore_SetMaxBytes(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		const maxBytes = 1 << 12
+		s := policy(maxBytes, func(string, lru.Value) {})
+		for i := 0; i < 100; i++ {
+			s.AddWithExpire(fmt.Sprintf("key%03d", i), bv("value"), time.Time{})
+		}
+		s.SetMaxBytes(maxBytes / 4)
+		if s.Bytes() > maxBytes/4 {
+			t.Fatalf("bytes %d exceed shrunk limit %d", s.Bytes(), maxBytes/4)
+		}
+		s.SetMaxBytes(maxBytes)
+		for i := 0; i < 100; i++ {
+			s.AddWithExpire(fmt.Sprintf("key%03d", i), bv("value"), time.Time{})
+		}
+		if s.Bytes() <= maxBytes/4 || s.Bytes() > maxBytes {
+			t.Fatalf("bytes %d should grow up to the restored limit %d", s.Bytes(), maxBytes)
+		}
+	})
+}
+
+// TestStore_RemoveOldest：测试 RemoveOldest 每次移除一条数据，直到为空
+func TestStore_RemoveOldest(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		s := policy(0, nil)
+		for i := 0; i < 10; i++ {
+			s.AddWithExpire(fmt.Sprintf("key%d", i), bv("v"), time.Time{})
+			s.Get("key0")
+		}
+		for i := 10; i > 0; i-- {
+			if s.Len() != i {
+				t.Fatalf("got len %d, want %d", s.Len(), i)
+			}
+			s.RemoveOldest()
+		}
+		s.RemoveOldest()
+		if s.Len() != 0 || s.Bytes() != 0 {
+			t.Fatalf("got len %d bytes %d, want empty", s.Len(), s.Bytes())
+		}
+	})
+}
+
+// TestCache_Policy：测试 Cache 按照 Policy 创建底层存储
+func TestCache_Policy(t *testing.T) {
+	forEachPolicy(t, func(t *testing.T, policy EvictionPolicy) {
+		c := &Cache{CacheBytes: 1 << 10, Policy: policy}
+		for i := 0; i < 100; i++ {
+			c.Add(fmt.Sprintf("key%d", i), bv("value"))
+		}
+		stats := c.Stats()
+		if stats.Bytes > 1<<10 || stats.Evictions == 0 || stats.Items+stats.Evictions != 100 {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+}
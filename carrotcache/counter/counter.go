@@ -0,0 +1,17 @@
+package counter
+
+import "sync/atomic"
+
+// AtomicInt：封装一个原子类，供 Group、concurrentcache 与 lru 等统计信息使用。
+// 放在结构体中时应作为第一个字段，或位于其他 64 位字段之后，以保证在 32 位平台上 64 位对齐
+type AtomicInt int64
+
+// Add：原子自增
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get：原子读取
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
@@ -0,0 +1,126 @@
+package fifo
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// Cache：先进先出缓存，按照数据进入缓存的顺序淘汰，访问不会改变淘汰顺序，不是并发安全的
+type Cache struct {
+	maxData   int64                    // 允许使用最大内存，为 0 表示不限制
+	nowData   int64                    // 当前已使用内存，包括每条数据的额外开销
+	list      *list.List               // 按照进入缓存的顺序排列，front 为最新
+	cache     map[string]*list.Element // 键是字符串，值是链表中对应节点的指针
+	OnEvicted func(key string, value lru.Value)
+}
+
+// entryOverhead：每条数据的额外开销，包括链表节点、entry 结构体及 map
+const entryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(lru.Entry{})) + lru.MapEntryOverhead
+
+// New：实例化 FIFO 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxData:   maxData,
+		list:      list.New(),
+		cache:     make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// Get：根据 key 查找 value，命中不会调整节点的位置
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*lru.Entry)
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*lru.Entry); !e.Expired(time.Now()) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期。
+// 修改已有数据不会改变其淘汰顺序
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*lru.Entry)
+		c.nowData += int64(value.Len()) - int64(e.Value.Len())
+		e.Value = value
+		e.Expire = expire
+	} else {
+		c.cache[key] = c.list.PushFront(&lru.Entry{Key: key, Value: value, Expire: expire})
+		c.nowData += int64(len(key)) + int64(value.Len()) + entryOverhead
+	}
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest：移除最早进入缓存的节点
+func (c *Cache) RemoveOldest() {
+	if ele := c.list.Back(); ele != nil {
+		c.removeElement(ele)
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	return lru.RemoveExpiredElements(c.removeElement, c.list)
+}
+
+// Len：获取 Cache 添加了多少条数据
+func (c *Cache) Len() int {
+	return c.list.Len()
+}
+
+// Bytes：获取 Cache 当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，为 0 表示不限制，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// removeElement：移除链表节点 ele 并维护映射关系与内存值
+func (c *Cache) removeElement(ele *list.Element) {
+	c.list.Remove(ele)
+	e := ele.Value.(*lru.Entry)
+	delete(c.cache, e.Key)
+	c.nowData -= e.Size(entryOverhead)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
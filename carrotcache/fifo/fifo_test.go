@@ -0,0 +1,24 @@
+package fifo
+
+import "testing"
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_RemoveOldest：测试按照进入缓存的顺序淘汰，访问不会改变淘汰顺序
+func TestCache_RemoveOldest(t *testing.T) {
+	c := New(int64(len("key1v1key2v2"))+2*entryOverhead, nil)
+	c.Add("key1", String("v1"))
+	c.Add("key2", String("v2"))
+	c.Get("key1")
+	c.Add("key3", String("v3")) // 尽管 key1 刚被访问过，仍然最先被淘汰
+	if _, ok := c.Get("key1"); ok || c.Len() != 2 {
+		t.Fatalf("RemoveOldest key1 failed")
+	}
+	if _, ok := c.Get("key2"); !ok {
+		t.Fatalf("key2 should survive")
+	}
+}
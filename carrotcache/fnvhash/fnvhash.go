@@ -0,0 +1,31 @@
+package fnvhash
+
+// FNV-1a 哈希，直接遍历字符串计算，避免 hash/fnv 转换为 []byte 及装箱到 hash.Hash 接口带来的内存分配。
+// 用于分片、条带及索引等需要在热路径上对 key 求哈希的场景，结果与 hash/fnv 的 New32a、New64a 相同
+
+const (
+	offset32 = 2166136261
+	prime32  = 16777619
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// Sum32：计算 key 的 32 位 FNV-1a 哈希值
+func Sum32(key string) uint32 {
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return h
+}
+
+// Sum64：计算 key 的 64 位 FNV-1a 哈希值
+func Sum64(key string) uint64 {
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
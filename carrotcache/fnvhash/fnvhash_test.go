@@ -0,0 +1,22 @@
+package fnvhash
+
+import (
+	"hash/fnv"
+	"testing"
+)
+
+// TestSum：测试结果与 hash/fnv 相同
+func TestSum(t *testing.T) {
+	for _, key := range []string{"", "a", "Tom", "carrotCache"} {
+		h32 := fnv.New32a()
+		h32.Write([]byte(key))
+		if got := Sum32(key); got != h32.Sum32() {
+			t.Fatalf("Sum32(%q) = %d, want %d", key, got, h32.Sum32())
+		}
+		h64 := fnv.New64a()
+		h64.Write([]byte(key))
+		if got := Sum64(key); got != h64.Sum64() {
+			t.Fatalf("Sum64(%q) = %d, want %d", key, got, h64.Sum64())
+		}
+	}
+}
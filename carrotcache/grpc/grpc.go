@@ -0,0 +1,294 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache"
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+	"github.com/Dongxiem/carrotCache/carrotcache/consistenthash"
+	"github.com/Dongxiem/carrotCache/carrotcache/logger"
+	"github.com/Dongxiem/carrotCache/carrotcache/metrics"
+	"github.com/Dongxiem/carrotCache/carrotcache/peers"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// 基于 gRPC 的节点间通信，实现了 cachepb.proto 中声明的 GroupCache 服务。
+// 与 HTTPPool 相比，每个远程节点只维护一条长连接，请求在连接上多路复用，省去了 HTTP/1.1 每次请求的开销。
+const defaultReplicas = 50
+
+// GRPCPool：既提供 GroupCache 服务，也根据具体的 key 选择远程节点并通过 gRPC 客户端获取缓存值
+type GRPCPool struct {
+	self  string              // 用来记录自己的地址，格式为 host:port
+	mu    sync.Mutex          // guards peers and grpcGetters
+	peers *consistenthash.Map // 类型是一致性哈希算法的 Map，用来根据具体的 key 选择节点。
+
+	// 映射远程节点与对应的 grpcGetter，每个 grpcGetter 持有一条到该节点的长连接
+	// keyed by e.g. "10.0.0.2:8008"
+	grpcGetters map[string]*grpcGetter
+
+	dialOpts []grpc.DialOption // 连接远程节点时使用的配置
+	logger   logger.Logger     // 日志，默认不输出
+}
+
+// PoolOption：NewGRPCPool 的可选配置项
+type PoolOption func(*GRPCPool)
+
+// WithLogger：设置 GRPCPool 的日志，默认不输出任何日志，l 为 nil 时同样不输出
+func WithLogger(l logger.Logger) PoolOption {
+	return func(p *GRPCPool) {
+		if l == nil {
+			l = logger.Nop()
+		}
+		p.logger = l
+	}
+}
+
+// WithDialOptions：设置连接远程节点时使用的配置，默认使用不加密的连接
+func WithDialOptions(opts ...grpc.DialOption) PoolOption {
+	return func(p *GRPCPool) {
+		p.dialOpts = opts
+	}
+}
+
+// NewGRPCPool：为每个节点初始化 gRPC 池，self 为本节点对外提供服务的地址
+func NewGRPCPool(self string, opts ...PoolOption) *GRPCPool {
+	p := &GRPCPool{
+		self:     self,
+		dialOpts: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		logger:   logger.Nop(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Register：将 GroupCache 服务注册到 gRPC 服务器上
+func (p *GRPCPool) Register(s *grpc.Server) {
+	pb.RegisterGroupCacheServer(s, &server{pool: p})
+}
+
+// Set：该方法实例化了一致性哈希算法，并且添加了传入的节点。
+// 已存在节点的连接会被复用，不再需要的连接会被关闭；连接任一节点失败时关闭新建的连接并返回错误，原来的节点保持不变。
+func (p *GRPCPool) Set(peers ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 先在临时的 map 中建立连接，全部成功后再替换，避免 PickPeer 选中尚未建立连接的节点
+	getters := make(map[string]*grpcGetter, len(peers))
+	for _, peer := range peers {
+		if _, ok := getters[peer]; ok || peer == p.self {
+			continue
+		}
+		if getter, ok := p.grpcGetters[peer]; ok {
+			getters[peer] = getter
+			continue
+		}
+		// grpc.Dial 不会阻塞等待连接建立，连接断开后也会自动重连
+		conn, err := grpc.Dial(peer, p.dialOpts...)
+		if err != nil {
+			for peer, getter := range getters {
+				if _, ok := p.grpcGetters[peer]; !ok {
+					getter.conn.Close()
+				}
+			}
+			return err
+		}
+		getters[peer] = &grpcGetter{conn: conn, client: pb.NewGroupCacheClient(conn), latency: metrics.NewHistogram()}
+	}
+	for peer, getter := range p.grpcGetters {
+		if _, ok := getters[peer]; !ok {
+			getter.conn.Close()
+		}
+	}
+	// 进行实例化，添加的节点进行补充到后面
+	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers.Add(peers...)
+	p.grpcGetters = getters
+	return nil
+}
+
+// Close：关闭到所有远程节点的连接
+func (p *GRPCPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for peer, getter := range p.grpcGetters {
+		if err := getter.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.grpcGetters, peer)
+	}
+	p.peers = nil
+	return firstErr
+}
+
+// PickPeer: 根据传入的key挑选一个节点
+func (p *GRPCPool) PickPeer(key string) (peers.PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	// 根据一致性哈希算法进行节点挑选
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		if logger.Enabled(p.logger, logger.DebugLevel) {
+			p.logger.Debug("pick peer", "server", p.self, "peer", peer, "key", key)
+		}
+		return p.grpcGetters[peer], true
+	}
+	return nil, false
+}
+
+// GetAll：返回除自身以外的所有节点
+func (p *GRPCPool) GetAll() []peers.PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]peers.PeerGetter, 0, len(p.grpcGetters))
+	for _, getter := range p.grpcGetters {
+		all = append(all, getter)
+	}
+	return all
+}
+
+var _ peers.PeerPicker = (*GRPCPool)(nil)
+
+// server：GroupCache 服务的服务端实现
+type server struct {
+	pb.UnimplementedGroupCacheServer
+	pool *GRPCPool
+}
+
+// group：通过 groupname 得到 group 实例，不存在时返回 NotFound
+func (s *server) group(name string) (*carrotcache.Group, error) {
+	group := carrotcache.GetGroup(name)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group: %s", name)
+	}
+	return group, nil
+}
+
+// Get：GroupCache 服务的 Get 方法，使用 group.GetContext(key) 获取缓存数据
+func (s *server) Get(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	if logger.Enabled(s.pool.logger, logger.DebugLevel) {
+		s.pool.logger.Debug("serve request", "server", s.pool.self, "method", "Get", "group", in.GetGroup(), "key", in.GetKey())
+	}
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	view, err := group.GetContext(ctx, in.GetKey())
+	// key 不存在不是服务端错误，通过 status 字段告知请求方
+	if errors.Is(err, carrotcache.ErrNotFound) {
+		return &pb.Response{Status: pb.Status_NOT_FOUND}, nil
+	}
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return carrotcache.NewResponse(view), nil
+}
+
+// GetMulti：GroupCache 服务的 GetMulti 方法，使用 group.GetMultiContext(keys) 批量获取缓存数据
+func (s *server) GetMulti(ctx context.Context, in *pb.BatchRequest) (*pb.BatchResponse, error) {
+	if logger.Enabled(s.pool.logger, logger.DebugLevel) {
+		s.pool.logger.Debug("serve request", "server", s.pool.self, "method", "GetMulti", "group", in.GetGroup(), "keys", len(in.GetKeys()))
+	}
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	values, errs := group.GetMultiContext(ctx, in.GetKeys())
+	return carrotcache.NewBatchResponse(in.GetKeys(), values, errs), nil
+}
+
+// Remove：GroupCache 服务的 Remove 方法，只移除本节点的缓存，由发起删除的节点负责通知其他节点
+func (s *server) Remove(ctx context.Context, in *pb.RemoveRequest) (*pb.RemoveResponse, error) {
+	if logger.Enabled(s.pool.logger, logger.DebugLevel) {
+		s.pool.logger.Debug("serve request", "server", s.pool.self, "method", "Remove", "group", in.GetGroup(), "key", in.GetKey())
+	}
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	group.RemoveLocally(in.GetKey())
+	return &pb.RemoveResponse{}, nil
+}
+
+// Set：GroupCache 服务的 Set 方法，将数据写入本节点的 mainCache
+func (s *server) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	if logger.Enabled(s.pool.logger, logger.DebugLevel) {
+		s.pool.logger.Debug("serve request", "server", s.pool.self, "method", "Set", "group", in.GetGroup(), "key", in.GetKey())
+	}
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	group.SetLocally(in.GetKey(), in.GetValue(), time.Duration(in.GetTtlMs())*time.Millisecond)
+	return &pb.SetResponse{}, nil
+}
+
+var _ pb.GroupCacheServer = (*server)(nil)
+
+// grpcGetter：持有到远程节点的长连接及对应的 gRPC 客户端，以及访问该节点的耗时直方图
+type grpcGetter struct {
+	conn    *grpc.ClientConn
+	client  pb.GroupCacheClient
+	latency *metrics.Histogram
+}
+
+// observe：记录一次请求的耗时，用法为 defer g.observe(time.Now())
+func (g *grpcGetter) observe(start time.Time) {
+	g.latency.Observe(time.Since(start))
+}
+
+// Get: 数据获取，ctx 被取消或超时时请求随之中断
+func (g *grpcGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	defer g.observe(time.Now())
+	res, err := g.client.Get(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+// GetMulti: 批量获取数据
+func (g *grpcGetter) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	defer g.observe(time.Now())
+	res, err := g.client.GetMulti(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+// Remove: 删除远程节点上的缓存数据
+func (g *grpcGetter) Remove(ctx context.Context, in *pb.RemoveRequest, out *pb.RemoveResponse) error {
+	defer g.observe(time.Now())
+	res, err := g.client.Remove(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+// Set: 将数据写入远程节点的缓存
+func (g *grpcGetter) Set(ctx context.Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	defer g.observe(time.Now())
+	res, err := g.client.Set(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+var _ peers.PeerGetter = (*grpcGetter)(nil)
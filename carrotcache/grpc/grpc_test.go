@@ -0,0 +1,105 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dongxiem/carrotCache/carrotcache"
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestGRPCPool：测试通过 gRPC 从远程节点获取、写入及删除缓存
+func TestGRPCPool(t *testing.T) {
+	g := carrotcache.NewGroup("grpc", 2<<10, carrotcache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "missing" {
+				return nil, carrotcache.ErrNotFound
+			}
+			return []byte("db:" + key), nil
+		}))
+	defer g.Close()
+
+	// 节点 a 在内存中的监听器上提供服务
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	NewGRPCPool("a").Register(s)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	// 节点 b 通过该监听器连接节点 a
+	b := NewGRPCPool("b", WithDialOptions(
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis.Dial()
+		})))
+	if err := b.Set("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if all := b.GetAll(); len(all) != 1 {
+		t.Fatalf("expect 1 peer, but %d got", len(all))
+	}
+	peer := b.grpcGetters["a"]
+
+	// 连接新节点失败时保留原来的节点与连接
+	dialOpts := b.dialOpts
+	b.dialOpts = nil
+	if err := b.Set("a", "b", "c"); err == nil {
+		t.Fatal("expect dial error without transport credentials")
+	}
+	b.dialOpts = dialOpts
+	if len(b.grpcGetters) != 1 || b.grpcGetters["a"] != peer {
+		t.Fatalf("failed Set should keep the previous peers, but %v got", b.grpcGetters)
+	}
+	for _, key := range []string{"Tom", "Jack", "Sam"} {
+		if getter, ok := b.PickPeer(key); ok && getter != peer {
+			t.Fatalf("%s should be picked from the previous peers", key)
+		}
+	}
+
+	ctx := context.Background()
+	res := &pb.Response{}
+	if err := peer.Get(ctx, &pb.Request{Group: "grpc", Key: "Tom"}, res); err != nil || string(res.GetValue()) != "db:Tom" {
+		t.Fatalf("Get failed, value = %q, err = %v", res.GetValue(), err)
+	}
+	if err := peer.Set(ctx, &pb.SetRequest{Group: "grpc", Key: "Tom", Value: []byte("630")}, &pb.SetResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := carrotcache.GetGroup("grpc").Get("Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("Set should update the remote cache, but %q got", view.String())
+	}
+	if err := peer.Remove(ctx, &pb.RemoveRequest{Group: "grpc", Key: "Tom"}, &pb.RemoveResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	res = &pb.Response{}
+	if err := peer.Get(ctx, &pb.Request{Group: "grpc", Key: "Tom"}, res); err != nil || string(res.GetValue()) != "db:Tom" {
+		t.Fatalf("Remove should invalidate the remote cache, but %q got", res.GetValue())
+	}
+	if err := peer.Get(ctx, &pb.Request{Group: "unknown", Key: "Tom"}, &pb.Response{}); err == nil {
+		t.Fatal("expect error for unknown group")
+	}
+	res = &pb.Response{}
+	if err := peer.Get(ctx, &pb.Request{Group: "grpc", Key: "missing"}, res); err != nil || res.GetStatus() != pb.Status_NOT_FOUND {
+		t.Fatalf("expect NOT_FOUND status, but %v, %v got", res.GetStatus(), err)
+	}
+	batch := &pb.BatchResponse{}
+	if err := peer.GetMulti(ctx, &pb.BatchRequest{Group: "grpc", Keys: []string{"Sam", "missing"}}, batch); err != nil {
+		t.Fatal(err)
+	}
+	if rs := batch.GetResponses(); len(rs) != 2 || string(rs[0].GetValue()) != "db:Sam" || rs[1].GetStatus() != pb.Status_NOT_FOUND {
+		t.Fatalf("unexpected batch response %v", rs)
+	}
+
+	// 每次请求的耗时都记录在该节点的直方图中
+	rec := httptest.NewRecorder()
+	b.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if expect := `carrotcache_peer_request_duration_seconds_count{peer="a"} 7`; !strings.Contains(rec.Body.String(), expect) {
+		t.Fatalf("metrics should contain %q, but got:\n%s", expect, rec.Body.String())
+	}
+}
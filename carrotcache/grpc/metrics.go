@@ -0,0 +1,25 @@
+package grpc
+
+import (
+	"net/http"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/metrics"
+)
+
+// MetricsHandler：返回以 Prometheus 文本格式导出指标的 http.Handler，与 gRPC 服务使用不同的端口，例如
+//
+//	http.Handle("/metrics", pool.MetricsHandler())
+func (p *GRPCPool) MetricsHandler() http.Handler {
+	return metrics.Handler(p.latencies)
+}
+
+// latencies：返回本节点访问各远程节点的耗时直方图
+func (p *GRPCPool) latencies() map[string]*metrics.Histogram {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	latencies := make(map[string]*metrics.Histogram, len(p.grpcGetters))
+	for peer, getter := range p.grpcGetters {
+		latencies[peer] = getter.latency
+	}
+	return latencies
+}
@@ -0,0 +1,194 @@
+package hotkey
+
+import (
+	"container/list"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+)
+
+const (
+	DefaultPromoteQPS = 100.0 / 60 // 默认的晋升阈值，相当于每分钟远程获取 100 次
+	DefaultHalfLife   = time.Minute
+	DefaultMaxKeys    = 10000 // 最多同时统计的 key 数量
+	defaultShards     = 16
+)
+
+// Config：Tracker 的配置，字段为零值时使用默认值
+type Config struct {
+	PromoteQPS float64       // 访问速率（次/秒）达到该值时晋升为热点
+	DemoteQPS  float64       // 热点 key 的访问速率低于该值时降温，默认为 PromoteQPS 的一半
+	HalfLife   time.Duration // 速率估计的半衰期，越短对访问速率的变化越敏感
+	MaxKeys    int           // 最多同时统计的 key 数量
+}
+
+// Tracker：基于指数衰减的热点 key 探测器，并发安全。
+// 每个 key 维护一个随时间指数衰减的访问计数，稳定访问速率为 r 时计数收敛于 r*HalfLife/ln2，
+// 因此可以由计数换算出近期的访问速率：过去的突发访问会随时间衰减，新出现的突发访问也不会被长时间平均稀释。
+// 按 key 的哈希分为多个分片，每个分片拥有独立的锁，并按最近访问顺序最多保存 MaxKeys/shards 个 key。
+type Tracker struct {
+	shards     []*shard
+	promoteQPS float64
+	demoteQPS  float64
+	halfLife   float64 // 半衰期，单位为秒
+}
+
+// shard：一个分片，链表按照最近访问时间排序，front 为最新，back 为最旧
+type shard struct {
+	mu     sync.Mutex
+	list   *list.List
+	keys   map[string]*list.Element
+	max    int
+	cooled []string // 因分片已满被淘汰的热点 key，等待 Cooled 返回
+}
+
+// keyStats：key 的统计信息
+type keyStats struct {
+	key   string
+	score float64   // 指数衰减的访问计数
+	last  time.Time // score 最后一次更新的时间
+	hot   bool      // 是否已晋升为热点
+}
+
+// New：创建 Tracker
+func New(cfg Config) *Tracker {
+	if cfg.PromoteQPS <= 0 {
+		cfg.PromoteQPS = DefaultPromoteQPS
+	}
+	if cfg.DemoteQPS <= 0 || cfg.DemoteQPS > cfg.PromoteQPS {
+		cfg.DemoteQPS = cfg.PromoteQPS / 2
+	}
+	if cfg.HalfLife <= 0 {
+		cfg.HalfLife = DefaultHalfLife
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = DefaultMaxKeys
+	}
+	perShard := (cfg.MaxKeys + defaultShards - 1) / defaultShards
+	t := &Tracker{
+		shards:     make([]*shard, defaultShards),
+		promoteQPS: cfg.PromoteQPS,
+		demoteQPS:  cfg.DemoteQPS,
+		halfLife:   cfg.HalfLife.Seconds(),
+	}
+	for i := range t.shards {
+		t.shards[i] = &shard{
+			list: list.New(),
+			keys: make(map[string]*list.Element),
+			max:  perShard,
+		}
+	}
+	return t
+}
+
+// Record：记录一次访问，返回该 key 当前是否为热点
+func (t *Tracker) Record(key string) bool {
+	s := t.shards[fnvhash.Sum32(key)%uint32(len(t.shards))]
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ele, ok := s.keys[key]
+	if !ok {
+		// 如果是第一次访问，分片已满时淘汰最久未访问的 key
+		if s.list.Len() >= s.max {
+			s.remove(s.list.Back())
+		}
+		ele = s.list.PushFront(&keyStats{key: key, last: now})
+		s.keys[key] = ele
+	} else {
+		s.list.MoveToFront(ele)
+	}
+	stat := ele.Value.(*keyStats)
+	stat.score = t.decay(stat, now) + 1
+	stat.last = now
+	if !stat.hot && t.rate(stat.score) >= t.promoteQPS {
+		stat.hot = true
+	}
+	return stat.hot
+}
+
+// Cooled：返回访问速率已低于降温阈值的热点 key，这些 key 不再被视为热点
+func (t *Tracker) Cooled() []string {
+	now := time.Now()
+	var cooled []string
+	for _, s := range t.shards {
+		s.mu.Lock()
+		cooled = append(cooled, s.cooled...)
+		s.cooled = nil
+		for ele := s.list.Front(); ele != nil; ele = ele.Next() {
+			stat := ele.Value.(*keyStats)
+			if stat.hot && t.rate(t.decay(stat, now)) < t.demoteQPS {
+				stat.hot = false
+				cooled = append(cooled, stat.key)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return cooled
+}
+
+// Estimate：返回 key 当前估计的访问速率（次/秒），未被统计的 key 返回 0
+func (t *Tracker) Estimate(key string) float64 {
+	s := t.shards[fnvhash.Sum32(key)%uint32(len(t.shards))]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ele, ok := s.keys[key]; ok {
+		return t.rate(t.decay(ele.Value.(*keyStats), time.Now()))
+	}
+	return 0
+}
+
+// HotKeys：返回当前的热点 key，按名称排序
+func (t *Tracker) HotKeys() []string {
+	var keys []string
+	for _, s := range t.shards {
+		s.mu.Lock()
+		for ele := s.list.Front(); ele != nil; ele = ele.Next() {
+			if stat := ele.Value.(*keyStats); stat.hot {
+				keys = append(keys, stat.key)
+			}
+		}
+		s.mu.Unlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len：返回当前统计的 key 数量
+func (t *Tracker) Len() int {
+	n := 0
+	for _, s := range t.shards {
+		s.mu.Lock()
+		n += s.list.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// decay：返回 stat 的访问计数衰减到 now 时刻的值
+func (t *Tracker) decay(stat *keyStats, now time.Time) float64 {
+	dt := now.Sub(stat.last).Seconds()
+	if dt <= 0 {
+		return stat.score
+	}
+	return stat.score * math.Exp(-math.Ln2*dt/t.halfLife)
+}
+
+// rate：将访问计数换算为访问速率（次/秒）
+func (t *Tracker) rate(score float64) float64 {
+	return score * math.Ln2 / t.halfLife
+}
+
+// remove：移除链表节点并删除映射关系，被移除的热点 key 需要降温
+func (s *shard) remove(ele *list.Element) {
+	s.list.Remove(ele)
+	stat := ele.Value.(*keyStats)
+	delete(s.keys, stat.key)
+	if stat.hot {
+		s.cooled = append(s.cooled, stat.key)
+	}
+}
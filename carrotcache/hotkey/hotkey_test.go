@@ -0,0 +1,120 @@
+package hotkey
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+)
+
+// TestRecord：测试访问速率达到阈值后晋升为热点
+func TestRecord(t *testing.T) {
+	// 半衰期为 1s 时，每秒 1 次的晋升阈值对应约 1.44 的访问计数
+	tracker := New(Config{PromoteQPS: 1, HalfLife: time.Second})
+	if tracker.Record("Tom") {
+		t.Fatal("Tom should not be hot after 1 get")
+	}
+	if !tracker.Record("Tom") {
+		t.Fatal("Tom should be hot after 2 gets")
+	}
+	if tracker.Record("Sam") {
+		t.Fatal("Sam should not be hot")
+	}
+}
+
+// TestCooled：测试热点 key 的访问速率衰减到降温阈值以下后被返回
+func TestCooled(t *testing.T) {
+	tracker := New(Config{PromoteQPS: 100, HalfLife: 10 * time.Millisecond})
+	tracker.Record("Tom")
+	if !tracker.Record("Tom") {
+		t.Fatal("Tom should be hot after 2 gets")
+	}
+	if cooled := tracker.Cooled(); len(cooled) != 0 {
+		t.Fatalf("Tom should still be hot, but %v cooled", cooled)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if cooled := tracker.Cooled(); !reflect.DeepEqual(cooled, []string{"Tom"}) {
+		t.Fatalf("Tom should be cooled, but %v got", cooled)
+	}
+	if cooled := tracker.Cooled(); len(cooled) != 0 {
+		t.Fatalf("Tom should only be cooled once, but %v got", cooled)
+	}
+}
+
+// TestBounded：测试并发统计时 key 的数量有上限，被淘汰的热点 key 同样需要降温
+func TestBounded(t *testing.T) {
+	tracker := New(Config{MaxKeys: 64})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				tracker.Record(strconv.Itoa(g*1000 + i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := tracker.Len(); n > 64 {
+		t.Fatalf("tracker should hold at most 64 keys, but %d got", n)
+	}
+
+	// 每个分片只能保存 1 个 key，找到与 Tom 位于同一分片的 key 将其挤出
+	tracker = New(Config{PromoteQPS: 0.001, MaxKeys: 1})
+	tracker.Record("Tom")
+	other := "Sam"
+	for i := 0; fnvhash.Sum32(other)%defaultShards != fnvhash.Sum32("Tom")%defaultShards; i++ {
+		other = "Sam" + strconv.Itoa(i)
+	}
+	tracker.Record(other)
+	if cooled := tracker.Cooled(); len(cooled) == 0 || cooled[0] != "Tom" {
+		t.Fatalf("evicted hot key Tom should be cooled, but %v got", cooled)
+	}
+}
+
+// TestSketchTracker：测试基于 Count-Min Sketch 的晋升、降温与查询
+func TestSketchTracker(t *testing.T) {
+	tracker := NewSketch(SketchConfig{Threshold: 4, HalvingTime: 20 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		if tracker.Record("Tom") {
+			t.Fatalf("Tom should not be hot after %d gets", i+1)
+		}
+	}
+	if !tracker.Record("Tom") {
+		t.Fatal("Tom should be hot after 4 gets")
+	}
+	if v := tracker.Estimate("Tom"); v != 4 {
+		t.Fatalf("estimate of Tom should be 4, but %v got", v)
+	}
+	if keys := tracker.HotKeys(); !reflect.DeepEqual(keys, []string{"Tom"}) {
+		t.Fatalf("hot keys should be [Tom], but %v got", keys)
+	}
+	if cooled := tracker.Cooled(); len(cooled) != 0 {
+		t.Fatalf("Tom should still be hot, but %v cooled", cooled)
+	}
+	// 两次减半后估计值降为 1，低于降温阈值 2
+	time.Sleep(45 * time.Millisecond)
+	if cooled := tracker.Cooled(); !reflect.DeepEqual(cooled, []string{"Tom"}) {
+		t.Fatalf("Tom should be cooled, but %v got", cooled)
+	}
+	if len(tracker.HotKeys()) != 0 {
+		t.Fatal("no key should be hot after cooling")
+	}
+}
+
+// BenchmarkSketchTracker_Record：并发记录多个热点 key 的访问，各 key 分布在不同的分片上
+func BenchmarkSketchTracker_Record(b *testing.B) {
+	tracker := NewSketch(SketchConfig{})
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			tracker.Record(keys[i%len(keys)])
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package hotkey
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/cmsketch"
+	"github.com/Dongxiem/carrotCache/carrotcache/fnvhash"
+)
+
+const (
+	DefaultThreshold   = 100         // 默认的晋升阈值，相当于每分钟远程获取 100 次
+	DefaultWidth       = 1 << 14     // Count-Min Sketch 每行计数器的默认数量
+	DefaultHalvingTime = time.Minute // 计数器减半的默认时间间隔
+	DefaultMaxHotKeys  = 10000       // 最多同时存在的热点 key 数量
+)
+
+// SketchConfig：SketchTracker 的配置，字段为零值时使用默认值
+type SketchConfig struct {
+	Threshold   uint32        // 访问次数估计值达到该值时晋升为热点
+	Demote      uint32        // 热点 key 的估计值低于该值时降温，默认为 Threshold 的一半
+	Width       int           // Count-Min Sketch 每行计数器的总数，由各分片平分，决定了内存占用与估计误差
+	HalvingTime time.Duration // 每隔该时间将所有计数器减半，使过去的访问逐渐衰减
+	MaxHotKeys  int           // 最多同时存在的热点 key 数量
+}
+
+// SketchTracker：基于 Count-Min Sketch 的热点 key 探测器，并发安全。
+// 与 Tracker 不同，它不为每个 key 保存统计信息，只使用固定大小的计数器估计访问频率，
+// 因此无论 key 的数量有多少，内存占用都是固定的；只有晋升为热点的 key 会被单独记录，且数量有上限。
+// 与 Tracker 一样按 key 的哈希分为多个分片，每个分片拥有独立的锁、Sketch 及热点 key，最多保存 MaxHotKeys/shards 个热点 key，
+// 热点 key 的每次访问都会调用 Record，分片避免了所有访问争抢同一把锁。
+type SketchTracker struct {
+	shards      []*sketchShard
+	threshold   uint32
+	demote      uint32
+	halvingTime time.Duration
+}
+
+// sketchShard：一个分片，所有字段由 mu 保护
+type sketchShard struct {
+	mu          sync.Mutex
+	sketch      *cmsketch.Sketch
+	hot         map[string]struct{} // 当前的热点 key
+	maxHot      int
+	lastHalving time.Time
+}
+
+// NewSketch：创建 SketchTracker
+func NewSketch(cfg SketchConfig) *SketchTracker {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = DefaultThreshold
+	}
+	if cfg.Demote == 0 || cfg.Demote > cfg.Threshold {
+		cfg.Demote = cfg.Threshold / 2
+	}
+	if cfg.Width <= 0 {
+		cfg.Width = DefaultWidth
+	}
+	if cfg.HalvingTime <= 0 {
+		cfg.HalvingTime = DefaultHalvingTime
+	}
+	if cfg.MaxHotKeys <= 0 {
+		cfg.MaxHotKeys = DefaultMaxHotKeys
+	}
+	t := &SketchTracker{
+		shards:      make([]*sketchShard, defaultShards),
+		threshold:   cfg.Threshold,
+		demote:      cfg.Demote,
+		halvingTime: cfg.HalvingTime,
+	}
+	now := time.Now()
+	for i := range t.shards {
+		t.shards[i] = &sketchShard{
+			sketch:      cmsketch.New((cfg.Width + defaultShards - 1) / defaultShards),
+			hot:         make(map[string]struct{}),
+			maxHot:      (cfg.MaxHotKeys + defaultShards - 1) / defaultShards,
+			lastHalving: now,
+		}
+	}
+	return t
+}
+
+// shard：返回 key 所在的分片
+func (t *SketchTracker) shard(key string) *sketchShard {
+	return t.shards[fnvhash.Sum32(key)%uint32(len(t.shards))]
+}
+
+// Record：记录一次访问，返回该 key 当前是否为热点
+func (t *SketchTracker) Record(key string) bool {
+	s := t.shard(key)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.halve(s, now)
+	n := s.sketch.Add(key)
+	if _, ok := s.hot[key]; ok {
+		return true
+	}
+	if n < t.threshold {
+		return false
+	}
+	// 热点 key 的数量已达上限时不再晋升，等待已有热点降温
+	if len(s.hot) >= s.maxHot {
+		return false
+	}
+	s.hot[key] = struct{}{}
+	return true
+}
+
+// Cooled：返回估计值已低于降温阈值的热点 key，这些 key 不再被视为热点
+func (t *SketchTracker) Cooled() []string {
+	now := time.Now()
+	var cooled []string
+	for _, s := range t.shards {
+		s.mu.Lock()
+		t.halve(s, now)
+		for key := range s.hot {
+			if s.sketch.Estimate(key) < t.demote {
+				delete(s.hot, key)
+				cooled = append(cooled, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return cooled
+}
+
+// Estimate：返回 key 近期访问次数的估计值
+func (t *SketchTracker) Estimate(key string) float64 {
+	s := t.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return float64(s.sketch.Estimate(key))
+}
+
+// HotKeys：返回当前的热点 key，按名称排序
+func (t *SketchTracker) HotKeys() []string {
+	keys := make([]string, 0)
+	for _, s := range t.shards {
+		s.mu.Lock()
+		for key := range s.hot {
+			keys = append(keys, key)
+		}
+		s.mu.Unlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// halve：距离分片上次减半超过 halvingTime 时，将分片的所有计数器减半，长时间无访问时可能连续减半多次，调用方需持有 s.mu
+func (t *SketchTracker) halve(s *sketchShard, now time.Time) {
+	for i := 0; now.Sub(s.lastHalving) >= t.halvingTime; i++ {
+		s.lastHalving = s.lastHalving.Add(t.halvingTime)
+		// 连续减半 32 次后所有计数器都已为零
+		if i >= 32 {
+			s.lastHalving = now
+			s.sketch.Clear()
+			return
+		}
+		s.sketch.Halve()
+	}
+}
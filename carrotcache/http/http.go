@@ -1,17 +1,24 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Dongxiem/carrotCache/carrotcache"
 	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
 	"github.com/Dongxiem/carrotCache/carrotcache/consistenthash"
+	"github.com/Dongxiem/carrotCache/carrotcache/logger"
+	"github.com/Dongxiem/carrotCache/carrotcache/metrics"
 	"github.com/Dongxiem/carrotCache/carrotcache/peers"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -36,17 +43,37 @@ type HTTPPool struct {
 	// 每一个远程节点对应一个 httpGetter，因为 httpGetter 与远程节点的地址 baseURL 有关
 	// keyed by e.g. "http://10.0.0.2:8008"
 	httpGetters map[string]*httpGetter
+
+	logger logger.Logger // 日志，默认不输出
+}
+
+// PoolOption：NewHTTPPool 的可选配置项
+type PoolOption func(*HTTPPool)
+
+// WithLogger：设置 HTTPPool 的日志，默认不输出任何日志，l 为 nil 时同样不输出
+func WithLogger(l logger.Logger) PoolOption {
+	return func(p *HTTPPool) {
+		if l == nil {
+			l = logger.Nop()
+		}
+		p.logger = l
+	}
 }
 
-// NewHTTPPool: 为每个节点初始化HTTP池
-func NewHTTPPool(self string) *HTTPPool {
-	return &HTTPPool{
+// NewHTTPPool: 为每个节点初始化HTTP池，opts 为可选配置项
+func NewHTTPPool(self string, opts ...PoolOption) *HTTPPool {
+	p := &HTTPPool{
 		self:     self,
 		basePath: defaultBasePath,
+		logger:   logger.Nop(),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
-// Log：日志打印
+// Log：日志打印，始终使用 log 包输出，不受 WithLogger 影响
 func (p *HTTPPool) Log(format string, v ...interface{}) {
 	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
 }
@@ -57,7 +84,9 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !strings.HasPrefix(r.URL.Path, p.basePath) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
-	p.Log("%s %s", r.Method, r.URL.Path)
+	if logger.Enabled(p.logger, logger.DebugLevel) {
+		p.logger.Debug("serve request", "server", p.self, "method", r.Method, "path", r.URL.Path)
+	}
 	// 约定访问路径格式为 /<basepath>/<groupname>/<key>
 	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
 	// 如果请求长度不为2则报错
@@ -74,22 +103,67 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
 		return
 	}
-	// 再使用 group.Get(key) 获取缓存数据
-	view, err := group.Get(key)
+	// PUT 请求将 body 中的数据写入本节点的 mainCache
+	if r.Method == http.MethodPut {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := &pb.SetRequest{}
+		if err = proto.Unmarshal(b, in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group.SetLocally(key, in.GetValue(), time.Duration(in.GetTtlMs())*time.Millisecond)
+		p.writeResponse(w, &pb.SetResponse{})
+		return
+	}
+	// POST 请求为批量获取，路径为 /<basepath>/<groupname>/，body 为 BatchRequest
+	if r.Method == http.MethodPost {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := &pb.BatchRequest{}
+		if err = proto.Unmarshal(b, in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values, errs := group.GetMultiContext(r.Context(), in.GetKeys())
+		p.writeResponse(w, carrotcache.NewBatchResponse(in.GetKeys(), values, errs))
+		return
+	}
+	// DELETE 请求只移除本节点的缓存，由发起删除的节点负责通知其他节点
+	if r.Method == http.MethodDelete {
+		group.RemoveLocally(key)
+		p.writeResponse(w, &pb.RemoveResponse{})
+		return
+	}
+	// 再使用 group.GetContext(key) 获取缓存数据，客户端断开连接时请求随之取消
+	view, err := group.GetContext(r.Context(), key)
+	// key 不存在不是服务端错误，通过 status 字段告知请求方
+	if errors.Is(err, carrotcache.ErrNotFound) {
+		p.writeResponse(w, &pb.Response{Status: pb.Status_NOT_FOUND})
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// 将得到的value作为proto消息写入响应主体
-	// 使用 proto.Marshal() 编码 HTTP 响应
-	body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice()})
+	// 将得到的value及其元数据作为proto消息写入响应主体
+	p.writeResponse(w, carrotcache.NewResponse(view))
+}
+
+// writeResponse：使用 proto.Marshal() 编码 HTTP 响应，并作为 httpResponse 的 body 返回
+func (p *HTTPPool) writeResponse(w http.ResponseWriter, m proto.Message) {
+	body, err := proto.Marshal(m)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
 	w.Header().Set("Content-Type", "application/octet-stream")
-	// 最终使用 w.Write() 将缓存值作为 httpResponse 的 body 返回
 	w.Write(body)
 }
 
@@ -102,9 +176,15 @@ func (p *HTTPPool) Set(peers ...string) {
 	// 添加的节点进行补充到后面
 	p.peers.Add(peers...)
 	// 为每一个节点创建了一个 HTTP 客户端 httpGetter
+	// 重新设置节点时保留已有节点的耗时直方图
+	old := p.httpGetters
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+		getter := &httpGetter{baseURL: peer + p.basePath, latency: metrics.NewHistogram()}
+		if prev, ok := old[peer]; ok {
+			getter.latency = prev.latency
+		}
+		p.httpGetters[peer] = getter
 	}
 }
 
@@ -115,29 +195,83 @@ func (p *HTTPPool) PickPeer(key string) (peers.PeerGetter, bool) {
 	defer p.mu.Unlock()
 	// 根据一致性哈希算法进行节点挑选
 	if peer := p.peers.Get(key); peer != "" && peer != p.self {
-		p.Log("Pick peer %s", peer)
+		if logger.Enabled(p.logger, logger.DebugLevel) {
+			p.logger.Debug("pick peer", "server", p.self, "peer", peer, "key", key)
+		}
 		return p.httpGetters[peer], true
 	}
 	return nil, false
 }
 
+// GetAll：返回除自身以外的所有节点
+func (p *HTTPPool) GetAll() []peers.PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]peers.PeerGetter, 0, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			all = append(all, getter)
+		}
+	}
+	return all
+}
+
 var _ peers.PeerPicker = (*HTTPPool)(nil)
 
-// httpGetter：存储的是 URL
+// httpGetter：存储的是 URL，以及访问该节点的耗时直方图
 type httpGetter struct {
 	baseURL string
+	latency *metrics.Histogram
 }
 
-// Get: 数据获取
-func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
-	u := fmt.Sprintf(
+// url：拼接请求的地址，格式为 <baseURL><groupname>/<key>，批量请求的 key 为空
+func (h *httpGetter) url(group, key string) string {
+	return fmt.Sprintf(
 		"%v%v/%v",
 		h.baseURL, // baseURL 表示将要访问的远程节点的地址
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
+		url.QueryEscape(group),
+		url.QueryEscape(key),
 	)
-	// 使用 http.Get() 方式获取返回值
-	res, err := http.Get(u)
+}
+
+// Get: 数据获取，ctx 被取消或超时时请求随之中断
+func (h *httpGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return h.do(ctx, http.MethodGet, h.url(in.GetGroup(), in.GetKey()), nil, out)
+}
+
+// GetMulti: 批量获取数据，所有 key 在一次 POST 请求中发送
+func (h *httpGetter) GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	return h.do(ctx, http.MethodPost, h.url(in.GetGroup(), ""), in, out)
+}
+
+// Remove: 删除远程节点上的缓存数据
+func (h *httpGetter) Remove(ctx context.Context, in *pb.RemoveRequest, out *pb.RemoveResponse) error {
+	return h.do(ctx, http.MethodDelete, h.url(in.GetGroup(), in.GetKey()), nil, out)
+}
+
+// Set: 将数据写入远程节点的缓存
+func (h *httpGetter) Set(ctx context.Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	return h.do(ctx, http.MethodPut, h.url(in.GetGroup(), in.GetKey()), in, out)
+}
+
+// do：发送携带 ctx 的请求，in 不为 nil 时编码后作为请求的 body，并将返回的 body 解码到 out 中
+func (h *httpGetter) do(ctx context.Context, method, u string, in, out proto.Message) error {
+	var body io.Reader
+	if in != nil {
+		b, err := proto.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encoding request body: %v", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	// 耗时包括读取响应的 body，Do 返回时 body 可能还没有全部到达
+	start := time.Now()
+	defer func() { h.latency.Observe(time.Since(start)) }()
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -148,12 +282,12 @@ func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
 	}
 
 	// 将消息转换为 []bytes 类型
-	bytes, err := ioutil.ReadAll(res.Body)
+	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return fmt.Errorf("reading response body: %v", err)
 	}
 	// 使用 proto.Unmarshal() 解码 HTTP 响应
-	if err = proto.Unmarshal(bytes, out); err != nil {
+	if err = proto.Unmarshal(b, out); err != nil {
 		return fmt.Errorf("decoding response body: %v", err)
 	}
 
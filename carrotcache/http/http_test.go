@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache"
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+	"github.com/Dongxiem/carrotCache/carrotcache/metrics"
+)
+
+// TestHTTPPool_GetMulti：测试通过一次 POST 请求从远程节点批量获取缓存
+func TestHTTPPool_GetMulti(t *testing.T) {
+	g := carrotcache.NewGroup("http-multi", 2<<10, carrotcache.GetterFunc(
+		func(key string) ([]byte, error) {
+			switch key {
+			case "missing":
+				return nil, carrotcache.ErrNotFound
+			case "broken":
+				return nil, fmt.Errorf("database unavailable")
+			}
+			return []byte("db:" + key), nil
+		}))
+	defer g.Close()
+	srv := httptest.NewServer(NewHTTPPool("self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath, latency: metrics.NewHistogram()}
+	out := &pb.BatchResponse{}
+	keys := []string{"Tom", "missing", "broken"}
+	if err := getter.GetMulti(context.Background(), &pb.BatchRequest{Group: "http-multi", Keys: keys}, out); err != nil {
+		t.Fatal(err)
+	}
+	rs := out.GetResponses()
+	if len(rs) != len(keys) {
+		t.Fatalf("expect %d responses, but %d got", len(keys), len(rs))
+	}
+	if string(rs[0].GetValue()) != "db:Tom" || rs[1].GetStatus() != pb.Status_NOT_FOUND ||
+		rs[2].GetStatus() != pb.Status_ERROR || rs[2].GetError() != "database unavailable" {
+		t.Fatalf("unexpected batch response %v", rs)
+	}
+}
+
+// TestHTTPPool_Latency：测试请求耗时包括读取响应 body 的时间
+func TestHTTPPool_Latency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 先返回响应头，body 延迟 20ms 到达
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write(nil)
+	}))
+	defer srv.Close()
+
+	p := NewHTTPPool("self")
+	p.Set(srv.URL)
+	peer, ok := p.PickPeer("Tom")
+	if !ok {
+		t.Fatal("expect Tom to be owned by the remote peer")
+	}
+	if err := peer.Get(context.Background(), &pb.Request{Group: "latency", Key: "Tom"}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, expect := range []string{
+		`carrotcache_peer_request_duration_seconds_bucket{peer="` + srv.URL + `",le="0.01"} 0`,
+		`carrotcache_peer_request_duration_seconds_count{peer="` + srv.URL + `"} 1`,
+	} {
+		if !strings.Contains(body, expect) {
+			t.Fatalf("metrics should contain %q, but got:\n%s", expect, body)
+		}
+	}
+}
+
+// TestHTTPPool_PickPeerAllocs：日志不输出调试信息时，挑选节点不应为日志参数分配内存
+func TestHTTPPool_PickPeerAllocs(t *testing.T) {
+	p := NewHTTPPool("http://self")
+	p.Set("http://peer")
+	want := testing.AllocsPerRun(100, func() { p.peers.Get("Tom") })
+	if n := testing.AllocsPerRun(100, func() { p.PickPeer("Tom") }); n > want {
+		t.Fatalf("PickPeer allocates %v times, consistent hash alone allocates %v", n, want)
+	}
+}
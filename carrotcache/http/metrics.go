@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/metrics"
+)
+
+// MetricsHandler：返回以 Prometheus 文本格式导出指标的 http.Handler，通常挂载在 /metrics 路径下，例如
+//
+//	mux.Handle("/metrics", pool.MetricsHandler())
+func (p *HTTPPool) MetricsHandler() http.Handler {
+	return metrics.Handler(p.latencies)
+}
+
+// latencies：返回本节点访问各远程节点的耗时直方图
+func (p *HTTPPool) latencies() map[string]*metrics.Histogram {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	latencies := make(map[string]*metrics.Histogram, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		latencies[peer] = getter.latency
+	}
+	return latencies
+}
@@ -0,0 +1,219 @@
+package lfu
+
+import (
+	"container/heap"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// agingFactor：自上次衰减以来的访问次数达到数据条数的 agingFactor 倍时，将所有数据的访问次数减半，
+// 与 TinyLFU 的重置相同，使过去的高频数据逐渐衰减，不会永远占据缓存
+const agingFactor = 10
+
+// Cache：最不经常使用缓存，淘汰访问次数最少的数据，访问次数相同时淘汰最久未访问的数据，访问次数会随时间衰减，不是并发安全的
+type Cache struct {
+	maxData   int64             // 允许使用最大内存，为 0 表示不限制
+	nowData   int64             // 当前已使用内存，包括每条数据的额外开销
+	heap      entryHeap         // 按照访问次数及最近访问时间排列的小顶堆，堆顶为下一个应被淘汰的数据
+	cache     map[string]*entry // 键是字符串，值是堆中对应的节点
+	tick      uint64            // 逻辑时钟，每次访问递增，用于比较访问的先后
+	accesses  uint64            // 自上次衰减以来的访问次数
+	OnEvicted func(key string, value lru.Value)
+}
+
+// entry：堆节点的数据类型
+type entry struct {
+	lru.Entry
+	freq  uint64 // 访问次数
+	last  uint64 // 最近一次访问时的逻辑时钟
+	index int    // 在堆中的下标
+}
+
+// entryOverhead：每条数据的额外开销，包括 entry 结构体、堆中的指针及 map
+const entryOverhead = int64(unsafe.Sizeof(entry{})+unsafe.Sizeof(&entry{})) + lru.MapEntryOverhead
+
+// New：实例化 LFU 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxData:   maxData,
+		cache:     make(map[string]*entry),
+		OnEvicted: onEvicted,
+	}
+}
+
+// Get：根据 key 查找 value，命中时访问次数加一
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeEntry(e)
+		return nil, false
+	}
+	c.touch(e)
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不增加访问次数也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if e, ok := c.cache[key]; ok && !e.Expired(time.Now()) {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期。
+// 修改已有数据视为一次访问；超出内存限制时不会淘汰刚刚写入的数据，除非只剩下它
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	e, ok := c.cache[key]
+	if ok {
+		c.nowData += int64(value.Len()) - int64(e.Value.Len())
+		e.Value = value
+		e.Expire = expire
+		c.touch(e)
+	} else {
+		c.tick++
+		e = &entry{Entry: lru.Entry{Key: key, Value: value, Expire: expire}, freq: 1, last: c.tick}
+		heap.Push(&c.heap, e)
+		c.cache[key] = e
+		c.nowData += int64(len(key)) + int64(value.Len()) + entryOverhead
+		c.age()
+	}
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.removeEntry(c.victim(e))
+	}
+}
+
+// victim：返回下一个应被淘汰的节点。新数据的访问次数最少，总是位于堆顶，
+// 因此 protect 位于堆顶时改为淘汰其次的节点，即堆顶的两个子节点中较小的一个
+func (c *Cache) victim(protect *entry) *entry {
+	if c.heap[0] != protect || len(c.heap) == 1 {
+		return c.heap[0]
+	}
+	if len(c.heap) == 2 || c.heap.Less(1, 2) {
+		return c.heap[1]
+	}
+	return c.heap[2]
+}
+
+// RemoveOldest：移除访问次数最少的节点
+func (c *Cache) RemoveOldest() {
+	if len(c.heap) > 0 {
+		c.removeEntry(c.heap[0])
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if e, ok := c.cache[key]; ok {
+		c.removeEntry(e)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	var expired []*entry
+	for _, e := range c.heap {
+		if e.Expired(now) {
+			expired = append(expired, e)
+		}
+	}
+	for _, e := range expired {
+		c.removeEntry(e)
+	}
+	return len(expired)
+}
+
+// Len：获取 Cache 添加了多少条数据
+func (c *Cache) Len() int {
+	return len(c.heap)
+}
+
+// Bytes：获取 Cache 当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，为 0 表示不限制，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// touch：记录一次访问并调整节点在堆中的位置
+func (c *Cache) touch(e *entry) {
+	c.tick++
+	e.freq++
+	e.last = c.tick
+	heap.Fix(&c.heap, e.index)
+	c.age()
+}
+
+// age：记录一次访问，访问次数达到数据条数的 agingFactor 倍时将所有数据的访问次数减半。
+// 减半后原本不同的访问次数可能变为相同，此时按最近访问时间排列，因此需要重建堆，均摊到每次访问的开销为 O(1)
+func (c *Cache) age() {
+	c.accesses++
+	if c.accesses < agingFactor*uint64(len(c.heap)) {
+		return
+	}
+	c.accesses = 0
+	for _, e := range c.heap {
+		e.freq /= 2
+	}
+	heap.Init(&c.heap)
+}
+
+// removeEntry：从堆中移除节点并维护映射关系与内存值
+func (c *Cache) removeEntry(e *entry) {
+	heap.Remove(&c.heap, e.index)
+	delete(c.cache, e.Key)
+	c.nowData -= e.Size(entryOverhead)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
+
+// entryHeap：实现 heap.Interface，访问次数少的在前，次数相同时最久未访问的在前
+type entryHeap []*entry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].last < h[j].last
+}
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
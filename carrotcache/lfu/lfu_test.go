@@ -0,0 +1,70 @@
+package lfu
+
+import (
+	"strconv"
+	"testing"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_RemoveOldest：测试淘汰访问次数最少的数据，次数相同时淘汰最久未访问的数据
+func TestCache_RemoveOldest(t *testing.T) {
+	c := New(int64(len("key1v1key2v2key3v3"))+3*entryOverhead, nil)
+	c.Add("key1", String("v1"))
+	c.Add("key2", String("v2"))
+	c.Add("key3", String("v3"))
+	c.Get("key1")
+	c.Get("key1")
+	c.Get("key3")
+	c.Add("key4", String("v4")) // key2 只被访问过一次
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("key2 should be evicted")
+	}
+	c.Add("key5", String("v5")) // key4 与 key5 都只被访问过一次，key4 更久未访问
+	if _, ok := c.Get("key4"); ok {
+		t.Fatalf("key4 should be evicted")
+	}
+	if _, ok := c.Get("key1"); !ok {
+		t.Fatalf("key1 should survive")
+	}
+}
+
+// TestCache_ProtectNew：测试新数据不会在写入时被立即淘汰
+func TestCache_ProtectNew(t *testing.T) {
+	c := New(int64(len("key1v1key2v2"))+2*entryOverhead, nil)
+	c.Add("key1", String("v1"))
+	c.Add("key2", String("v2"))
+	c.Get("key1")
+	c.Get("key2")
+	c.Get("key2")
+	c.Add("key3", String("v3")) // key3 的访问次数最少，淘汰其次的 key1
+	if _, ok := c.Peek("key3"); !ok {
+		t.Fatalf("key3 should not be evicted on its own insert")
+	}
+	if _, ok := c.Peek("key1"); ok {
+		t.Fatalf("key1 should be evicted")
+	}
+}
+
+// TestCache_Aging：测试过去的高频数据随访问次数衰减，最终被新的热点数据淘汰
+func TestCache_Aging(t *testing.T) {
+	c := New(int64(len("key1v1key2v2"))+2*entryOverhead, nil)
+	c.Add("old", String("v1"))
+	for i := 0; i < 50; i++ {
+		c.Get("old")
+	}
+	for i := 0; i < 200; i++ {
+		key := "k" + strconv.Itoa(i%10)
+		c.Add(key, String("v2"))
+		for j := 0; j < 3; j++ {
+			c.Get(key)
+		}
+	}
+	if _, ok := c.Peek("old"); ok {
+		t.Fatalf("old should be evicted after its frequency decays")
+	}
+}
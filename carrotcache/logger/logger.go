@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Level：日志级别
+type Level int
+
+const (
+	DebugLevel Level = iota // 调试信息，例如每次缓存命中
+	InfoLevel               // 一般信息
+	WarnLevel               // 警告，例如从远程节点获取失败后回退到本地
+	ErrorLevel              // 错误
+)
+
+// String：返回日志级别的名称
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARN"
+	case ErrorLevel:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// Logger：结构化日志接口，keyvals 为交替出现的键值对，例如 "key", key, "peer", peer
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// LevelEnabler：Logger 可以选择实现的接口，用于在构造日志参数之前判断该级别的日志是否会被输出，
+// 避免在缓存命中等热路径上为被丢弃的日志分配内存
+type LevelEnabler interface {
+	Enabled(level Level) bool
+}
+
+// Enabled：判断 l 是否会输出 level 级别的日志，未实现 LevelEnabler 的 Logger 视为全部输出
+func Enabled(l Logger, level Level) bool {
+	if e, ok := l.(LevelEnabler); ok {
+		return e.Enabled(level)
+	}
+	return true
+}
+
+// nopLogger：丢弃所有日志
+type nopLogger struct{}
+
+func (nopLogger) Enabled(level Level) bool { return false }
+
+func (nopLogger) Debug(msg string, keyvals ...interface{}) {}
+func (nopLogger) Info(msg string, keyvals ...interface{})  {}
+func (nopLogger) Warn(msg string, keyvals ...interface{})  {}
+func (nopLogger) Error(msg string, keyvals ...interface{}) {}
+
+// Nop：返回一个丢弃所有日志的 Logger，作为默认值使用
+func Nop() Logger {
+	return nopLogger{}
+}
+
+// stdLogger：基于标准库 log.Logger 的实现，低于 min 的日志会被丢弃
+type stdLogger struct {
+	l   *log.Logger
+	min Level
+}
+
+// New：使用标准库 log.Logger 创建 Logger，l 为 nil 时使用 log 包默认的 Logger
+func New(l *log.Logger, min Level) Logger {
+	if l == nil {
+		l = log.New(log.Writer(), log.Prefix(), log.Flags())
+	}
+	return &stdLogger{l: l, min: min}
+}
+
+// Enabled：实现 LevelEnabler 接口，低于 min 的日志不会输出
+func (s *stdLogger) Enabled(level Level) bool { return level >= s.min }
+
+func (s *stdLogger) Debug(msg string, keyvals ...interface{}) { s.log(DebugLevel, msg, keyvals) }
+func (s *stdLogger) Info(msg string, keyvals ...interface{})  { s.log(InfoLevel, msg, keyvals) }
+func (s *stdLogger) Warn(msg string, keyvals ...interface{})  { s.log(WarnLevel, msg, keyvals) }
+func (s *stdLogger) Error(msg string, keyvals ...interface{}) { s.log(ErrorLevel, msg, keyvals) }
+
+// log：按照 "[carrotCache] LEVEL msg k1=v1 k2=v2" 的格式输出日志
+func (s *stdLogger) log(level Level, msg string, keyvals []interface{}) {
+	if level < s.min {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("[carrotCache] ")
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(fmt.Sprint(keyvals[i]))
+		b.WriteString("=")
+		if i+1 < len(keyvals) {
+			b.WriteString(fmt.Sprint(keyvals[i+1]))
+		} else {
+			b.WriteString("MISSING")
+		}
+	}
+	s.l.Output(3, b.String())
+}
@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// TestStdLogger：测试日志的级别过滤与键值对格式
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(log.New(&buf, "", 0), InfoLevel)
+
+	l.Debug("cache hit", "key", "Tom")
+	if buf.Len() != 0 {
+		t.Fatalf("debug log should be dropped, but %q got", buf.String())
+	}
+	l.Warn("failed to get from peer", "key", "Tom", "err")
+	expect := "[carrotCache] WARN failed to get from peer key=Tom err=MISSING\n"
+	if buf.String() != expect {
+		t.Fatalf("expect %q, but %q got", expect, buf.String())
+	}
+	if Enabled(l, DebugLevel) || !Enabled(l, WarnLevel) || Enabled(Nop(), ErrorLevel) {
+		t.Fatal("Enabled should follow the minimum level")
+	}
+}
@@ -0,0 +1,49 @@
+package lru
+
+import (
+	"container/list"
+	"time"
+)
+
+// 各淘汰策略共用的节点数据及过期处理，策略的节点类型嵌入 Entry 并添加自己的字段，例如 2Q 所在的队列、LFU 的访问次数
+
+// Entry：缓存中的一条数据
+type Entry struct {
+	// 在节点中仍保存每个值对应的 key 的好处在于：淘汰节点时，需要用 key 从字典中删除对应的映射
+	Key    string
+	Value  Value
+	Expire time.Time // 过期时间，零值表示永不过期
+}
+
+// Expired：判断节点在 now 时刻是否已经过期
+func (e *Entry) Expired(now time.Time) bool {
+	return !e.Expire.IsZero() && now.After(e.Expire)
+}
+
+// Size：节点所占用的内存，overhead 为所属策略中每条数据的额外开销
+func (e *Entry) Size(overhead int64) int64 {
+	return int64(len(e.Key)) + int64(e.Value.Len()) + overhead
+}
+
+// expirable：嵌入了 Entry 的节点类型
+type expirable interface {
+	Expired(now time.Time) bool
+}
+
+// RemoveExpiredElements：从末尾开始遍历 lists 中的每个链表，对已经过期的节点调用 remove，返回过期节点的数量。
+// 链表节点的值需为嵌入了 Entry 的指针类型，remove 可以将当前节点从链表中移除
+func RemoveExpiredElements(remove func(ele *list.Element), lists ...*list.List) int {
+	now := time.Now()
+	n := 0
+	for _, l := range lists {
+		for ele := l.Back(); ele != nil; {
+			prev := ele.Prev()
+			if ele.Value.(expirable).Expired(now) {
+				remove(ele)
+				n++
+			}
+			ele = prev
+		}
+	}
+	return n
+}
@@ -2,23 +2,32 @@ package lru
 
 import (
 	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/counter"
 )
 
 // Cache：创建结构体 方便实现后续的增改删查工作
 type Cache struct {
+	gets      counter.AtomicInt             // Get 的调用次数，放在开头以保证 64 位对齐
+	hits      counter.AtomicInt             // Get 的命中次数
+	evictions counter.AtomicInt             // 因内存超限或过期被淘汰的数据条数，不包括主动删除
 	maxData   int64                         // 允许使用最大内存
-	nowData   int64                         // 当前已使用内存
+	nowData   int64                         // 当前已使用内存，包括每条数据的额外开销 EntryOverhead
 	list      *list.List                    // LRU底层数据结构：双向链表
 	cache     map[string]*list.Element      // 键是字符串，值是双向链表中对应节点的指针
 	OnEvicted func(key string, value Value) // 某条记录被移除时的回调函数
 }
 
-// entry：双向链表的节点的数据类型，所以我们这次实现的LRU底层结构是双向链表
-type entry struct {
-	// 在链表中仍保存每个值对应的 key 的好处在于：淘汰队首节点时，需要用 key 从字典中删除对应的映射
-	key   string
-	value Value
-}
+// 每条数据除 key 与 value 本身之外还需要额外的内存，以下为 64 位平台上的估计值，会计入已使用内存
+const (
+	// MapEntryOverhead：map[string]*T 中每条数据的开销，包括 key 的字符串头、指针及 tophash，并按照平均装载因子约 6/8 折算
+	MapEntryOverhead = int64((unsafe.Sizeof("") + unsafe.Sizeof(uintptr(0)) + 1) * 8 / 6)
+	// EntryOverhead：Cache 中每条数据的额外开销，包括链表节点、Entry 结构体及 map。双向链表的节点值为 *Entry，
+	// value 本身（包括装箱到接口中的结构体）的内存由 value.Len() 报告
+	EntryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(Entry{})) + MapEntryOverhead
+)
 
 // Value：实现Value 接口的任意类型
 type Value interface {
@@ -38,12 +47,20 @@ func New(maxData int64, onEvicted func(string, Value)) *Cache {
 
 // Get：实现缓存数据获取功能，根据key进行value的查找，并返回一个是否查找成功的标志
 func (c *Cache) Get(key string) (value Value, ok bool) {
+	c.gets.Add(1)
 	// 1.第一步是从字典中找到对应的双向链表的节点
 	if ele, ok := c.cache[key]; ok {
-		// 2.将链表中的节点 ele 移动到队尾，这里约定front作为队尾
+		kv := ele.Value.(*Entry)
+		// 2.已经过期的节点视为未命中，顺便将其惰性删除
+		if kv.Expired(time.Now()) {
+			c.evictions.Add(1)
+			c.removeElement(ele)
+			return nil, false
+		}
+		// 3.将链表中的节点 ele 移动到队尾，这里约定front作为队尾
 		c.list.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		return kv.value, true
+		c.hits.Add(1)
+		return kv.Value, true
 	}
 	return
 }
@@ -52,34 +69,70 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 func (c *Cache) RemoveOldest() {
 	ele := c.list.Back() // 取队首节点
 	if ele != nil {
-		c.list.Remove(ele)
-		kv := ele.Value.(*entry)
-		// 从map中删除该节点的映射关系
-		delete(c.cache, kv.key)
-		// 更新内存值
-		c.nowData -= int64(len(kv.key)) + int64(kv.value.Len())
-		// 若回调函数不为nil，则调用回调函数
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+		c.evictions.Add(1)
+		c.removeElement(ele)
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：从队首开始遍历，移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	n := RemoveExpiredElements(c.removeElement, c.list)
+	c.evictions.Add(int64(n))
+	return n
+}
+
+// removeElement：移除链表节点 ele 并维护映射关系与内存值
+func (c *Cache) removeElement(ele *list.Element) {
+	c.list.Remove(ele)
+	kv := ele.Value.(*Entry)
+	// 从map中删除该节点的映射关系
+	delete(c.cache, kv.Key)
+	// 更新内存值
+	c.nowData -= kv.Size(EntryOverhead)
+	// 若回调函数不为nil，则调用回调函数
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.Key, kv.Value)
+	}
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*Entry); !e.Expired(time.Now()) {
+			return e.Value, true
 		}
 	}
+	return nil, false
 }
 
-// Add：实现新增/修改功能
+// Add：实现新增/修改功能，新增的数据永不过期
 func (c *Cache) Add(key string, value Value) {
-	// 1.1 如果key在Map中存在，则更新对应节点的值，并将该节点移到队尾。
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	// 1.1 如果key在Map中存在，则更新对应节点的值及过期时间，并将该节点移到队尾。
 	if ele, ok := c.cache[key]; ok {
 		c.list.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		c.nowData += int64(value.Len()) - int64(kv.value.Len()) // 更新内存
-		kv.value = value
+		kv := ele.Value.(*Entry)
+		c.nowData += int64(value.Len()) - int64(kv.Value.Len()) // 更新内存
+		kv.Value = value
+		kv.Expire = expire
 	} else {
 		// 1.2 如果key在Map不存在，则向队尾进行添加新节点，并在Map中添加映射关系
-		ele := c.list.PushFront(&entry{key, value})
+		ele := c.list.PushFront(&Entry{key, value, expire})
 		// 添加Map映射关系
 		c.cache[key] = ele
 		// 更新内存
-		c.nowData += int64(len(key)) + int64(value.Len())
+		c.nowData += int64(len(key)) + int64(value.Len()) + EntryOverhead
 	}
 
 	// 2.更新 c.nbytes，如果超过了设定的最大值 c.maxBytes，则移除最少访问的节点。
@@ -92,3 +145,32 @@ func (c *Cache) Add(key string, value Value) {
 func (c *Cache) Len() int {
 	return c.list.Len()
 }
+
+// Bytes：获取 Cache 当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存，为 0 表示不限制，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	for c.maxData != 0 && c.nowData > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// Stats：Cache 的统计信息
+type Stats struct {
+	Gets      int64 // Get 的调用次数
+	Hits      int64 // Get 的命中次数
+	Evictions int64 // 因内存超限或过期被淘汰的数据条数
+}
+
+// Stats：返回统计信息的快照，计数器使用原子操作维护，可以与其他方法并发调用
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Gets:      c.gets.Get(),
+		Hits:      c.hits.Get(),
+		Evictions: c.evictions.Get(),
+	}
+}
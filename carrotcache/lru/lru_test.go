@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -32,8 +33,8 @@ func TestCache_Add(t *testing.T) {
 	lru.Add("key", String("1"))
 	lru.Add("key", String("111"))
 
-	if lru.nowData != int64(len("key")+len("111")) {
-		t.Fatal("expected nowData is 6 but got : ", lru.nowData)
+	if lru.nowData != int64(len("key")+len("111"))+EntryOverhead {
+		t.Fatal("expected nowData is 6+EntryOverhead but got : ", lru.nowData)
 	}
 }
 
@@ -42,8 +43,8 @@ func TestCache_RemoveOldest(t *testing.T) {
 	k1, k2, k3 := "key1", "key2", "key3"
 	v1, v2, v3 := "value1", "value2", "value3"
 
-	cap := len(k1 + k2 + v1 + v2) // 设置容量
-	lru := New(int64(cap), nil)   // 设置一个最大容量为cap的Cache
+	cap := int64(len(k1+k2+v1+v2)) + 2*EntryOverhead // 设置容量，刚好容纳两条数据
+	lru := New(cap, nil)                             // 设置一个最大容量为cap的Cache
 	lru.Add(k1, String(v1))
 	lru.Add(k2, String(v2))
 	lru.Add(k3, String(v3)) // 此时会将k1挤出去
@@ -64,7 +65,7 @@ func TestOnEvicted(t *testing.T) {
 		keys = append(keys, key)
 	}
 
-	lru := New(int64(10), callback) // New Cache的时候传入callback回调函数
+	lru := New(int64(10)+2*EntryOverhead, callback) // New Cache的时候传入callback回调函数，除额外开销外可用 10 字节
 
 	lru.Add("key1", String("123456"))
 	fmt.Printf("the cap of key:key1 + string:123456 is %d \n", int64(len("key1"))+int64(len("123456")))
@@ -84,3 +85,56 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+// TestCache_Expire：测试过期数据在 Get 时视为未命中，并能被 RemoveExpired 清理
+func TestCache_Expire(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.AddWithExpire("key1", String("v1"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key2", String("v2"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key3", String("v3"), time.Now().Add(time.Hour))
+	lru.Add("key4", String("v4"))
+
+	if _, ok := lru.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if lru.Len() != 3 {
+		t.Fatalf("expired key1 should be removed lazily, len = %d", lru.Len())
+	}
+	if n := lru.RemoveExpired(); n != 1 || lru.Len() != 2 {
+		t.Fatalf("RemoveExpired removed %d, len = %d", n, lru.Len())
+	}
+	if lru.nowData != int64(len("key3v3key4v4"))+2*EntryOverhead {
+		t.Fatalf("unexpected nowData %d", lru.nowData)
+	}
+	if _, ok := lru.Get("key3"); !ok {
+		t.Fatalf("key3 should not expire")
+	}
+}
+
+// TestCache_Remove：测试根据 key 移除节点
+func TestCache_Remove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("v1"))
+	lru.Add("key2", String("v2"))
+	lru.Remove("key1")
+	lru.Remove("unknown")
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 || lru.nowData != int64(len("key2v2"))+EntryOverhead {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+// TestCache_Stats：测试统计 Get、命中及淘汰次数，主动删除不计入淘汰
+func TestCache_Stats(t *testing.T) {
+	cap := int64(len("key1v1key2v2")) + 2*EntryOverhead
+	lru := New(cap, nil)
+	lru.Add("key1", String("v1"))
+	lru.Add("key2", String("v2"))
+	lru.Add("key3", String("v3")) // 淘汰 key1
+	lru.Get("key1")
+	lru.Get("key2")
+	lru.Remove("key3")
+	want := Stats{Gets: 2, Hits: 1, Evictions: 1}
+	if got := lru.Stats(); got != want {
+		t.Fatalf("expect %+v, but %+v got", want, got)
+	}
+}
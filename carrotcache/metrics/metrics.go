@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache"
+)
+
+// 以 Prometheus 文本格式导出指标，不依赖任何第三方库，供 HTTPPool 与 GRPCPool 共用
+// 参见 https://prometheus.io/docs/instrumenting/exposition_formats/
+
+// 节点请求耗时直方图的桶上限，单位为秒
+var latencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram：并发安全的耗时直方图，counts[i] 记录落在第 i 个桶内（非累积）的样本数，最后一个为 +Inf 桶。
+// 64 位字段放在开头，以保证在 32 位平台上原子操作的对齐要求
+type Histogram struct {
+	sum    int64 // 样本总和，单位为纳秒
+	count  uint64
+	counts []uint64
+}
+
+// NewHistogram：创建使用 latencyBuckets 的直方图
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+}
+
+// Observe：记录一次耗时
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Handler：返回导出指标的 http.Handler，latencies 在每次请求时调用，返回各远程节点的耗时直方图
+func Handler(latencies func() map[string]*Histogram) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w, latencies())
+	})
+}
+
+// groupCounter：Group 的计数器指标
+type groupCounter struct {
+	name string
+	help string
+	get  func(s *carrotcache.Stats) int64
+}
+
+var groupCounters = []groupCounter{
+	{"carrotcache_gets_total", "Number of Get requests.", func(s *carrotcache.Stats) int64 { return s.Gets.Get() }},
+	{"carrotcache_main_cache_hits_total", "Number of mainCache hits.", func(s *carrotcache.Stats) int64 { return s.MainCacheHits.Get() }},
+	{"carrotcache_hot_cache_hits_total", "Number of hotCache hits.", func(s *carrotcache.Stats) int64 { return s.HotCacheHits.Get() }},
+	{"carrotcache_misses_total", "Number of cache misses that required a load.", func(s *carrotcache.Stats) int64 { return s.Loads.Get() }},
+	{"carrotcache_loads_executed_total", "Number of loads executed after singleflight deduplication.", func(s *carrotcache.Stats) int64 { return s.LoadsExecuted.Get() }},
+	{"carrotcache_peer_loads_total", "Number of successful loads from peers.", func(s *carrotcache.Stats) int64 { return s.PeerLoads.Get() }},
+	{"carrotcache_peer_errors_total", "Number of failed loads from peers.", func(s *carrotcache.Stats) int64 { return s.PeerErrors.Get() }},
+	{"carrotcache_local_loads_total", "Number of successful loads from the Getter.", func(s *carrotcache.Stats) int64 { return s.LocalLoads.Get() }},
+	{"carrotcache_local_load_errors_total", "Number of failed loads from the Getter.", func(s *carrotcache.Stats) int64 { return s.LocalLoadErrs.Get() }},
+	{"carrotcache_negative_cache_hits_total", "Number of negative cache hits.", func(s *carrotcache.Stats) int64 { return s.NegativeHits.Get() }},
+	{"carrotcache_refreshes_total", "Number of background refreshes of stale entries.", func(s *carrotcache.Stats) int64 { return s.Refreshes.Get() }},
+	{"carrotcache_batch_loads_total", "Number of coalesced BatchGetter.GetMany calls.", func(s *carrotcache.Stats) int64 { return s.BatchLoads.Get() }},
+}
+
+// Write：写出所有 Group 的指标以及 latencies 中本节点访问各远程节点的耗时直方图
+func Write(out io.Writer, latencies map[string]*Histogram) error {
+	w := bufio.NewWriter(out)
+	groups := carrotcache.Groups()
+	stats := make([]carrotcache.Stats, len(groups))
+	for i, g := range groups {
+		stats[i] = g.Stats()
+	}
+
+	for _, c := range groupCounters {
+		writeHeader(w, c.name, c.help, "counter")
+		for i, g := range groups {
+			writeSample(w, c.name, []string{"group", g.Name()}, float64(c.get(&stats[i])))
+		}
+	}
+
+	writeHeader(w, "carrotcache_loads_in_flight", "Number of singleflight loads currently in flight.", "gauge")
+	for _, g := range groups {
+		writeSample(w, "carrotcache_loads_in_flight", []string{"group", g.Name()}, float64(g.InFlightLoads()))
+	}
+
+	caches := []struct {
+		label string
+		which carrotcache.CacheType
+	}{{"main", carrotcache.MainCache}, {"hot", carrotcache.HotCache}, {"negative", carrotcache.NegativeCache}}
+	cacheMetrics := []struct {
+		name, help, typ string
+	}{
+		{"carrotcache_cache_bytes", "Bytes used by the cache.", "gauge"},
+		{"carrotcache_cache_items", "Number of items in the cache.", "gauge"},
+		{"carrotcache_cache_gets_total", "Number of cache lookups.", "counter"},
+		{"carrotcache_cache_hits_total", "Number of cache hits.", "counter"},
+		{"carrotcache_cache_evictions_total", "Number of items removed from the cache.", "counter"},
+	}
+	for i, m := range cacheMetrics {
+		writeHeader(w, m.name, m.help, m.typ)
+		for _, g := range groups {
+			for _, c := range caches {
+				cs := g.CacheStats(c.which)
+				v := []int64{cs.Bytes, cs.Items, cs.Gets, cs.Hits, cs.Evictions}[i]
+				writeSample(w, m.name, []string{"group", g.Name(), "cache", c.label}, float64(v))
+			}
+		}
+	}
+
+	peers := make([]string, 0, len(latencies))
+	for peer := range latencies {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+
+	const latency = "carrotcache_peer_request_duration_seconds"
+	writeHeader(w, latency, "Latency of requests sent to peers.", "histogram")
+	for _, peer := range peers {
+		h := latencies[peer]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += atomic.LoadUint64(&h.counts[i])
+			writeSample(w, latency+"_bucket", []string{"peer", peer, "le", formatFloat(le)}, float64(cumulative))
+		}
+		cumulative += atomic.LoadUint64(&h.counts[len(latencyBuckets)])
+		writeSample(w, latency+"_bucket", []string{"peer", peer, "le", "+Inf"}, float64(cumulative))
+		writeSample(w, latency+"_sum", []string{"peer", peer}, time.Duration(atomic.LoadInt64(&h.sum)).Seconds())
+		writeSample(w, latency+"_count", []string{"peer", peer}, float64(atomic.LoadUint64(&h.count)))
+	}
+	return w.Flush()
+}
+
+// writeHeader：写出指标的 HELP 与 TYPE 行
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// writeSample：写出一个样本，labels 为交替出现的标签名与标签值
+func writeSample(w *bufio.Writer, name string, labels []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// labelEscaper：标签值中的反斜杠、双引号与换行需要转义
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache"
+)
+
+// TestHandler：测试以 Prometheus 文本格式导出 Group 指标与节点耗时直方图
+func TestHandler(t *testing.T) {
+	g := carrotcache.NewGroup("metrics", 2<<10, carrotcache.GetterFunc(
+		func(key string) ([]byte, error) { return []byte(key), nil }))
+	defer g.Close()
+	g.Get("Tom")
+	g.Get("Tom")
+
+	h := NewHistogram()
+	h.Observe(3 * time.Millisecond)
+	handler := Handler(func() map[string]*Histogram {
+		return map[string]*Histogram{"http://localhost:8002": h}
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, expect := range []string{
+		"# TYPE carrotcache_gets_total counter\n",
+		`carrotcache_gets_total{group="metrics"} 2`,
+		`carrotcache_main_cache_hits_total{group="metrics"} 1`,
+		`carrotcache_cache_items{group="metrics",cache="main"} 1`,
+		`carrotcache_loads_in_flight{group="metrics"} 0`,
+		`carrotcache_peer_request_duration_seconds_bucket{peer="http://localhost:8002",le="0.0025"} 0`,
+		`carrotcache_peer_request_duration_seconds_bucket{peer="http://localhost:8002",le="0.005"} 1`,
+		`carrotcache_peer_request_duration_seconds_bucket{peer="http://localhost:8002",le="+Inf"} 1`,
+		`carrotcache_peer_request_duration_seconds_count{peer="http://localhost:8002"} 1`,
+	} {
+		if !strings.Contains(body, expect) {
+			t.Fatalf("metrics should contain %q, but got:\n%s", expect, body)
+		}
+	}
+}
@@ -1,14 +1,26 @@
 package peers
 
-import pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+import (
+	"context"
+
+	pb "github.com/Dongxiem/carrotCache/carrotcache/cachepb"
+)
 
 // PeerPicker：这是一个接口，根据传入的 key 选择相应节点 PeerGetter。
 type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
+	// GetAll 返回除自身以外的所有节点，用于广播删除等操作
+	GetAll() []PeerGetter
 }
 
 // PeerGetter：这是一个接口，用于从对应 group 查找缓存值。
 type PeerGetter interface {
-	// 第二个参数使用 cachepb.pb.go 中的数据类型
-	Get(in *pb.Request, out *pb.Response) error
+	// ctx 用于取消请求或设置超时，后两个参数使用 cachepb.pb.go 中的数据类型
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+	// GetMulti 用于在一次请求中查找多个缓存值，out.Responses 与 in.Keys 一一对应
+	GetMulti(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error
+	// Remove 用于删除对应 group 中的缓存值
+	Remove(ctx context.Context, in *pb.RemoveRequest, out *pb.RemoveResponse) error
+	// Set 用于将数据写入对应 group 的缓存中
+	Set(ctx context.Context, in *pb.SetRequest, out *pb.SetResponse) error
 }
@@ -1,15 +1,23 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
 
-// call：代表正在进行中，或已经结束的请求。使用 sync.WaitGroup 避免重入
+// call：代表正在进行中，或已经结束的请求。使用 done 通道通知等待者，等待者可以随时放弃等待
 type call struct {
-	wg  sync.WaitGroup	// 用于阻塞这个调用 call 的其他请求
-	val interface{}		// 函数执行后的结果
-	err error			// 函数执行后的 error
+	done    chan struct{}      // 请求结束时关闭，用于唤醒这个调用 call 的其他请求
+	val     interface{}        // 函数执行后的结果
+	err     error              // 函数执行后的 error
+	panic   *PanicError        // fn 发生 panic 时记录的值，不为 nil 时每个等待者都会重新 panic
+	waiters int                // 仍在等待结果的调用方数量，由 Group.mu 保护
+	cancel  context.CancelFunc // 所有调用方都放弃等待时取消 fn 使用的 ctx，为 nil 表示不取消
 }
 
-
 // Group 是 singleflight 的主数据结构，管理不同 key 的请求(call)
 type Group struct {
 	mu sync.Mutex       // 保护 m
@@ -18,41 +26,125 @@ type Group struct {
 
 // Do：执行并返回给定函数的结果，确保一次仅对给定键进行一次执行。
 // 如果出现重复请求尽量，则重复的 caller 将等待原始请求完成并收到相同的结果。
-// 并发协程之间不需要消息传递，非常适合 sync.WaitGroup。
-// 	wg.Add(1) 计数加1。
-// 	wg.Wait() 阻塞，直到资源被释放。
-// 	wg.Done() 计数减1
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext：与 Do 相同，但每个 caller（包括第一个）在等待期间如果自己的 ctx 被取消，都会放弃等待并返回 ctx.Err()。
+// fn 在新的协程中执行，其结果由所有 caller 共享，因此不能因为某一个 caller 被取消而失败：
+// 传给 fn 的 ctx 保留第一个 caller 的 ctx 中的值，但只有在所有 caller 都放弃等待时才会被取消，
+// 此时该请求也不再被复用，之后的 caller 会重新执行 fn。
+func (g *Group) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
 	// g.mu 是保护 Group 的成员变量 m 不被并发读写而加上的锁
 	g.mu.Lock()
+	// 如果获取当前key的函数正在被执行，则等待其执行完毕后获取它的执行结果，否则发起新的请求
+	c, ok := g.m[key]
+	if ok {
+		c.waiters++
+	} else {
+		fctx, cancel := context.WithCancel(detached{ctx})
+		c = g.start(key, cancel, func() (interface{}, error) { return fn(fctx) })
+		c.waiters = 1
+	}
+	g.mu.Unlock()
+
+	// 等待请求结束，或者自己的 ctx 被取消
+	select {
+	case <-c.done:
+		// 请求结束，返回结果；fn 发生 panic 时在等待者的协程中重新 panic，由调用方（例如 net/http）决定如何恢复
+		if c.panic != nil {
+			panic(c.panic)
+		}
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		// 已经没有人关心结果，取消 fn 并让之后的 caller 重新发起请求
+		if c.waiters == 0 && c.cancel != nil {
+			c.cancel()
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Go：key 已经有请求在执行时直接返回 false；否则在新的协程中执行 fn 并返回 true，执行期间的重复请求同样会等待其结果。
+// fn 发生 panic 时只有等待结果的调用方会重新 panic，没有等待者时 panic 被丢弃，不会使进程退出。
+// 适用于后台刷新等不关心结果的场景，避免为每次重复请求都创建一个等待的协程
+func (g *Group) Go(key string, fn func() (interface{}, error)) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.m[key]; ok {
+		return false
+	}
+	g.start(key, nil, fn)
+	return true
+}
+
+// start：登记 key 对应的请求并在新的协程中执行 fn，调用方需持有 g.mu
+func (g *Group) start(key string, cancel context.CancelFunc, fn func() (interface{}, error)) *call {
 	// 进行延迟初始化，延迟初始化的目的很简单，提高内存使用效率
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	// 如果获取当前key的函数正在被执行，则阻塞等待执行中的，等待其执行完毕后获取它的执行结果
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		// 如果请求正在进行中，则等待，直到 wg 被释放。
-		c.wg.Wait()
-		// 请求结束，返回结果
-		return c.val, c.err
-	}
-	c := new(call)
-	// 发起请求前计数加一
-	c.wg.Add(1)
+	c := &call{done: make(chan struct{}), cancel: cancel}
 	// 添加到 g.m，表明 key 已经有对应的请求在处理
 	g.m[key] = c
-	g.mu.Unlock()
+	go func() {
+		// 执行获取 key 的函数，并将结果赋值给这个 Call。fn 在新的协程中执行，panic 必须在这里恢复，否则整个进程会退出
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					c.panic = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			c.val, c.err = fn()
+		}()
+		// 请求结束，唤醒所有等待者
+		close(c.done)
+
+		g.mu.Lock()
+		// 重新上锁，并将该 key 剔除，下一个 key 进来可以进行访问了；所有等待者都已放弃时 key 可能已经被新的请求占用
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	}()
+	return c
+}
 
-	// 执行获取 key 的函数，并将结果赋值给这个 Call
-	c.val, c.err = fn()
-	// 请求结束
-	c.wg.Done()
+// PanicError：fn 发生的 panic，等待者重新 panic 时使用，Stack 为 fn 所在协程的调用栈
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error：实现 error 接口，便于 recover 之后记录日志
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: fn panicked: %v\n\n%s", p.Value, p.Stack)
+}
 
+// detached：保留父 ctx 中的值，但不继承其取消与超时
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// InFlight：返回当前正在执行中的请求数量
+func (g *Group) InFlight() int {
 	g.mu.Lock()
-	// 重新上锁，并将该 key 剔除，下一个 key 进来可以进行访问了
-	delete(g.m, key)
-	g.mu.Unlock()
-	// 返回结果
-	return c.val, c.err
+	defer g.mu.Unlock()
+	return len(g.m)
 }
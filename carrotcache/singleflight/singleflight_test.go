@@ -1,7 +1,9 @@
 package singleflight
 
 import (
+	"context"
 	"testing"
+	"time"
 )
 
 // TestDo：测试 Do 的作用
@@ -16,3 +18,157 @@ func TestDo(t *testing.T) {
 		t.Errorf("Do v = %v, error = %v", v, err)
 	}
 }
+
+// TestDoContextCancel：测试等待者的 ctx 被取消时可以放弃等待，而不影响正在执行的请求
+func TestDoContextCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	result := make(chan interface{})
+	go func() {
+		v, _ := g.Do("key", func() (interface{}, error) {
+			<-release
+			return "bar", nil
+		})
+		result <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.DoContext(ctx, "key", func(context.Context) (interface{}, error) {
+		t.Fatal("fn should not be called twice")
+		return nil, nil
+	}); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, but %v got", err)
+	}
+
+	close(release)
+	if v := <-result; v != "bar" {
+		t.Fatalf("Do v = %v", v)
+	}
+}
+
+// TestDoContextShared：测试第一个 caller 被取消不会影响其他 caller，所有 caller 都放弃等待时 fn 的 ctx 才被取消
+func TestDoContextShared(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fnCtx := make(chan context.Context, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		fnCtx <- ctx
+		select {
+		case <-release:
+			return "bar", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	leader, cancelLeader := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := g.DoContext(leader, "key", fn)
+		errc <- err
+	}()
+	ctx := <-fnCtx
+	result := make(chan interface{})
+	go func() {
+		v, _ := g.DoContext(context.Background(), "key", fn)
+		result <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelLeader()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("leader should stop waiting, but %v got", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("fn should not be cancelled while other callers are waiting")
+	}
+	close(release)
+	if v := <-result; v != "bar" {
+		t.Fatalf("waiter should get the shared result, but %v got", v)
+	}
+
+	// 唯一的 caller 放弃等待时取消 fn，之后的 caller 重新执行 fn
+	release = make(chan struct{})
+	only, cancelOnly := context.WithCancel(context.Background())
+	go func() {
+		_, err := g.DoContext(only, "key", fn)
+		errc <- err
+	}()
+	ctx = <-fnCtx
+	cancelOnly()
+	<-errc
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("fn should be cancelled once all callers have given up")
+	}
+	close(release)
+	if v, err := g.DoContext(context.Background(), "key", fn); v != "bar" || err != nil {
+		t.Fatalf("expect a new call, but %v, %v got", v, err)
+	}
+}
+
+// TestGo：测试 Go 在后台执行 fn，执行期间同一个 key 的 Go 被忽略而 Do 会等待其结果
+func TestGo(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	if !g.Go("key", func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}) {
+		t.Fatal("first Go should start fn")
+	}
+	if g.Go("key", func() (interface{}, error) {
+		t.Fatal("fn should not be called twice")
+		return nil, nil
+	}) {
+		t.Fatal("Go should return false while key is in flight")
+	}
+
+	result := make(chan interface{})
+	go func() {
+		v, _ := g.Do("key", func() (interface{}, error) { return "baz", nil })
+		result <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if v := <-result; v != "bar" {
+		t.Fatalf("Do should wait for the background call, but %v got", v)
+	}
+}
+
+// TestDoPanic：测试 fn 发生 panic 时进程不会退出，每个等待者都重新 panic，之后的请求重新执行 fn
+func TestDoPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	panics := make(chan interface{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer func() { panics <- recover() }()
+			g.Do("key", func() (interface{}, error) {
+				<-release
+				panic("boom")
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		p, ok := (<-panics).(*PanicError)
+		if !ok || p.Value != "boom" {
+			t.Fatalf("expect PanicError boom, but %v got", p)
+		}
+	}
+	if v, err := g.Do("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Fatalf("Do v = %v, error = %v", v, err)
+	}
+	// 没有等待者时 panic 被丢弃
+	done := make(chan struct{})
+	g.Go("go", func() (interface{}, error) {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+}
@@ -0,0 +1,287 @@
+package tinylfu
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/cmsketch"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// W-TinyLFU 淘汰策略，参见 https://arxiv.org/abs/1512.00727
+// 新数据先进入一个很小的窗口 LRU，从窗口中淘汰的数据作为候选者，与主缓存中即将被淘汰的数据比较访问频率，
+// 频率更高者才能留在主缓存中，因此一次性扫描的大量冷数据无法挤出真正的热点数据。
+// 主缓存为分段 LRU（SLRU）：新进入主缓存的数据位于 probation 段，再次被访问后晋升到 protected 段。
+
+const (
+	windowPercent    = 1  // 窗口 LRU 占总内存的百分比
+	protectedPercent = 80 // protected 段占主缓存内存的百分比
+	minSketchWidth   = 1 << 10
+	maxSketchWidth   = 1 << 20
+	avgEntryBytes    = 64 // 用于根据内存上限估计数据条数，从而确定 Count-Min Sketch 的大小
+	sampleFactor     = 10 // 访问次数达到 sketch 宽度的 sampleFactor 倍时，将所有计数器减半
+)
+
+// 数据所在的段
+const (
+	window = iota
+	probation
+	protected
+)
+
+// Cache：W-TinyLFU 缓存，与 lru.Cache 一样按照内存进行限制，不是并发安全的
+type Cache struct {
+	maxData       int64 // 允许使用最大内存，为 0 表示不限制
+	nowData       int64 // 当前已使用内存，包括每条数据的额外开销
+	windowMax     int64 // 窗口 LRU 允许使用的最大内存
+	protectedMax  int64 // protected 段允许使用的最大内存
+	windowData    int64
+	probationData int64
+	protectedData int64
+	lists         [3]*list.List // 依次为 window、probation、protected，front 为最近访问
+	cache         map[string]*list.Element
+	sketch        *cmsketch.Sketch // 记录访问频率
+	samples       int              // 自上次减半以来的访问次数
+	sampleSize    int              // 访问次数达到该值时将所有计数器减半
+	OnEvicted     func(key string, value lru.Value)
+}
+
+// entry：链表节点的数据类型
+type entry struct {
+	lru.Entry
+	segment int // 所在的段
+}
+
+// entryOverhead：每条数据的额外开销，包括链表节点、entry 结构体及 map
+const entryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(entry{})) + lru.MapEntryOverhead
+
+// New：实例化 W-TinyLFU 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	width := int(maxData / avgEntryBytes)
+	if width < minSketchWidth {
+		width = minSketchWidth
+	}
+	if width > maxSketchWidth {
+		width = maxSketchWidth
+	}
+	c := &Cache{
+		maxData:      maxData,
+		windowMax:    maxData * windowPercent / 100,
+		protectedMax: (maxData - maxData*windowPercent/100) * protectedPercent / 100,
+		cache:        make(map[string]*list.Element),
+		sketch:       cmsketch.New(width),
+		sampleSize:   width * sampleFactor,
+		OnEvicted:    onEvicted,
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	return c
+}
+
+// Get：根据 key 查找 value，无论是否命中都会记录一次访问频率
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	c.record(key)
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*entry)
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	c.touch(ele)
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*entry); !e.Expired(time.Now()) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*entry)
+		delta := int64(value.Len()) - int64(e.Value.Len())
+		c.resize(e, delta)
+		e.Value = value
+		e.Expire = expire
+		c.touch(ele)
+	} else {
+		// 新数据总是先进入窗口 LRU
+		e := &entry{Entry: lru.Entry{Key: key, Value: value, Expire: expire}, segment: window}
+		c.cache[key] = c.lists[window].PushFront(e)
+		c.resize(e, e.Size(entryOverhead))
+	}
+	c.evict()
+}
+
+// RemoveOldest：淘汰下一个应被淘汰的节点，依次从 probation、protected、window 段的末尾选择
+func (c *Cache) RemoveOldest() {
+	if ele := c.victim(); ele != nil {
+		c.removeElement(ele)
+		return
+	}
+	if ele := c.lists[window].Back(); ele != nil {
+		c.removeElement(ele)
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	return lru.RemoveExpiredElements(c.removeElement, c.lists[:]...)
+}
+
+// Len：获取 Cache 添加了多少条数据
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes：获取 Cache 当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nowData
+}
+
+// SetMaxBytes：修改允许使用的最大内存并按比例调整各段的上限，超出新的上限时立即淘汰，频率统计的宽度保持不变
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	c.windowMax = maxData * windowPercent / 100
+	c.protectedMax = (maxData - c.windowMax) * protectedPercent / 100
+	for c.protectedData > c.protectedMax {
+		c.move(c.lists[protected].Back(), probation)
+	}
+	c.evict()
+}
+
+// Frequency：返回 key 访问频率的估计值
+func (c *Cache) Frequency(key string) uint32 {
+	return c.sketch.Estimate(key)
+}
+
+// record：记录一次访问，访问次数达到 sampleSize 时将所有计数器减半，使频率信息保持新鲜
+func (c *Cache) record(key string) {
+	c.sketch.Add(key)
+	c.samples++
+	if c.samples >= c.sampleSize {
+		c.sketch.Halve()
+		c.samples /= 2
+	}
+}
+
+// touch：命中后调整节点所在的位置
+func (c *Cache) touch(ele *list.Element) {
+	e := ele.Value.(*entry)
+	switch e.segment {
+	case window, protected:
+		c.lists[e.segment].MoveToFront(ele)
+	case probation:
+		// probation 段的数据再次被访问，晋升到 protected 段
+		c.move(ele, protected)
+		// protected 段超出限制时，将其最久未访问的数据降级回 probation 段
+		for c.protectedData > c.protectedMax {
+			back := c.lists[protected].Back()
+			if back == nil || back == c.cache[e.Key] {
+				break
+			}
+			c.move(back, probation)
+		}
+	}
+}
+
+// evict：窗口 LRU 超出限制时，将其末尾的数据作为候选者尝试进入主缓存；总内存超出限制时继续淘汰
+func (c *Cache) evict() {
+	if c.maxData == 0 {
+		return
+	}
+	mainMax := c.maxData - c.windowMax
+	for c.windowData > c.windowMax {
+		candidate := c.lists[window].Back()
+		ce := candidate.Value.(*entry)
+		admitted := true
+		// 主缓存没有足够空间时，候选者需要与即将被淘汰的数据比较访问频率
+		for c.probationData+c.protectedData+ce.Size(entryOverhead) > mainMax {
+			victim := c.victim()
+			if victim == nil {
+				break
+			}
+			if c.sketch.Estimate(ce.Key) <= c.sketch.Estimate(victim.Value.(*entry).Key) {
+				admitted = false
+				break
+			}
+			c.removeElement(victim)
+		}
+		if !admitted {
+			c.removeElement(candidate)
+			continue
+		}
+		c.move(candidate, probation)
+	}
+	// 单条数据超过主缓存限制等情况下，按顺序继续淘汰直到满足总内存限制
+	for c.nowData > c.maxData && len(c.cache) > 0 {
+		c.RemoveOldest()
+	}
+}
+
+// victim：返回主缓存中下一个应被淘汰的节点，主缓存为空时返回 nil
+func (c *Cache) victim() *list.Element {
+	if ele := c.lists[probation].Back(); ele != nil {
+		return ele
+	}
+	return c.lists[protected].Back()
+}
+
+// move：将节点移动到 segment 段的最前面
+func (c *Cache) move(ele *list.Element, segment int) {
+	e := ele.Value.(*entry)
+	c.lists[e.segment].Remove(ele)
+	c.resize(e, -e.Size(entryOverhead))
+	e.segment = segment
+	c.cache[e.Key] = c.lists[segment].PushFront(e)
+	c.resize(e, e.Size(entryOverhead))
+}
+
+// resize：更新节点所在段及总的内存使用量
+func (c *Cache) resize(e *entry, delta int64) {
+	c.nowData += delta
+	switch e.segment {
+	case window:
+		c.windowData += delta
+	case probation:
+		c.probationData += delta
+	case protected:
+		c.protectedData += delta
+	}
+}
+
+// removeElement：移除节点并维护映射关系与内存值
+func (c *Cache) removeElement(ele *list.Element) {
+	e := ele.Value.(*entry)
+	c.lists[e.segment].Remove(ele)
+	delete(c.cache, e.Key)
+	c.resize(e, -e.Size(entryOverhead))
+	// 若回调函数不为nil，则调用回调函数
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
@@ -0,0 +1,97 @@
+package tinylfu
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_Get：测试缓存的添加与获取
+func TestCache_Get(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("123123123"))
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "123123123" {
+		t.Fatalf("cache hit key1 = 123123123 failed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+	c.Add("key1", String("1"))
+	if c.Bytes() != int64(len("key11"))+entryOverhead || c.Len() != 1 {
+		t.Fatalf("unexpected bytes %d after update", c.Bytes())
+	}
+	c.Remove("key1")
+	if _, ok := c.Get("key1"); ok || c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+// TestCache_Expire：测试过期数据视为未命中并能被清理
+func TestCache_Expire(t *testing.T) {
+	c := New(int64(0), nil)
+	c.AddWithExpire("key1", String("v1"), time.Now().Add(-time.Second))
+	c.AddWithExpire("key2", String("v2"), time.Now().Add(-time.Second))
+	c.Add("key3", String("v3"))
+	if _, ok := c.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if n := c.RemoveExpired(); n != 1 || c.Len() != 1 {
+		t.Fatalf("RemoveExpired removed %d, len = %d", n, c.Len())
+	}
+}
+
+// TestCache_ScanResistance：测试频繁访问的数据不会被一次性扫描的冷数据挤出
+func TestCache_ScanResistance(t *testing.T) {
+	var evicted []string
+	const capacity = 100 * (10 + entryOverhead)
+	c := New(capacity, func(key string, value lru.Value) {
+		evicted = append(evicted, key)
+	})
+	// 每条数据占用 10 字节及额外开销，缓存最多容纳 100 条
+	hot := make([]string, 50)
+	for i := range hot {
+		hot[i] = "hot" + strconv.Itoa(100 + i)[1:] + "xx"
+		for j := 0; j < 5; j++ {
+			if _, ok := c.Get(hot[i]); !ok {
+				c.Add(hot[i], String("vvv"))
+			}
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := "scan" + strconv.Itoa(1000 + i)[1:]
+		if _, ok := c.Get(key); !ok {
+			c.Add(key, String("v"))
+		}
+	}
+	for _, key := range hot {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("hot key %s should survive the scan", key)
+		}
+	}
+	if c.Bytes() > capacity {
+		t.Fatalf("cache should hold at most %d bytes, but %d got", capacity, c.Bytes())
+	}
+	if len(evicted) == 0 {
+		t.Fatal("OnEvicted should be called")
+	}
+}
+
+// TestCache_RemoveOldest：测试 RemoveOldest 每次淘汰一条数据
+func TestCache_RemoveOldest(t *testing.T) {
+	c := New(int64(0), nil)
+	for i := 0; i < 3; i++ {
+		c.Add(strconv.Itoa(i), String("v"))
+	}
+	c.RemoveOldest()
+	if c.Len() != 2 {
+		t.Fatalf("expect 2 items, but %d got", c.Len())
+	}
+}
@@ -0,0 +1,213 @@
+package twoq
+
+import (
+	"container/list"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// 2Q 淘汰策略，参见 Johnson & Shasha, "2Q: A Low Overhead High Performance Buffer Management Replacement Algorithm"
+// 新数据先进入 FIFO 队列 A1in，从 A1in 淘汰的数据只保留 key，记录在幽灵队列 A1out 中；
+// 数据被淘汰后不久再次被添加（命中 A1out）时，才认为它是真正的热点，放入 LRU 队列 Am。
+// 因此只被访问一次的数据不会进入 Am，一次性扫描无法挤出热点数据。
+
+const (
+	inPercent    = 25 // A1in 占总内存的百分比
+	ghostPercent = 25 // A1out 占用的内存上限相对总内存的百分比，幽灵 key 同样计入 Bytes 并占用 maxData
+)
+
+// 数据所在的队列
+const (
+	a1in = iota
+	am
+)
+
+// Cache：2Q 缓存，与 lru.Cache 一样按照内存进行限制，不是并发安全的
+type Cache struct {
+	maxData   int64 // 允许使用最大内存，为 0 表示不限制
+	nowData   int64 // 当前已使用内存，包括每条数据的额外开销
+	inMax     int64 // A1in 允许使用的最大内存
+	inData    int64
+	ghostMax  int64                    // A1out 允许使用的最大内存
+	ghostData int64                    // A1out 已使用的内存，包括每个 key 的额外开销 ghostOverhead
+	lists     [2]*list.List            // 依次为 A1in、Am，front 为最新
+	ghosts    *list.List               // A1out，只保存 key，front 为最新
+	cache     map[string]*list.Element // 在 A1in 或 Am 中的数据
+	ghostKeys map[string]*list.Element // 在 A1out 中的 key
+	OnEvicted func(key string, value lru.Value)
+}
+
+// entry：链表节点的数据类型
+type entry struct {
+	lru.Entry
+	queue int // 所在的队列
+}
+
+// entryOverhead：每条数据的额外开销，包括链表节点、entry 结构体及 map
+const entryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(entry{})) + lru.MapEntryOverhead
+
+// ghostOverhead：A1out 中每个 key 的额外开销，包括链表节点、装箱到接口中的字符串头及 map
+const ghostOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof("")) + lru.MapEntryOverhead
+
+// New：实例化 2Q 缓存
+func New(maxData int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxData:   maxData,
+		inMax:     maxData * inPercent / 100,
+		ghostMax:  maxData * ghostPercent / 100,
+		lists:     [2]*list.List{list.New(), list.New()},
+		ghosts:    list.New(),
+		cache:     make(map[string]*list.Element),
+		ghostKeys: make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// Get：根据 key 查找 value，命中 Am 时移动到最前面，命中 A1in 时不调整位置
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*entry)
+	// 已经过期的节点视为未命中，顺便将其惰性删除
+	if e.Expired(time.Now()) {
+		c.removeElement(ele)
+		return nil, false
+	}
+	if e.queue == am {
+		c.lists[am].MoveToFront(ele)
+	}
+	return e.Value, true
+}
+
+// Peek：根据 key 查找 value，不调整淘汰顺序也不删除过期数据，只读取不修改，可以与其他 Peek 并发调用
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		if e := ele.Value.(*entry); !e.Expired(time.Now()) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add：实现新增/修改功能，新增的数据永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire：实现新增/修改功能，并设置数据的过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*entry)
+		c.resize(e, int64(value.Len())-int64(e.Value.Len()))
+		e.Value = value
+		e.Expire = expire
+		if e.queue == am {
+			c.lists[am].MoveToFront(ele)
+		}
+	} else {
+		e := &entry{Entry: lru.Entry{Key: key, Value: value, Expire: expire}, queue: a1in}
+		// 命中 A1out 说明数据在被淘汰后不久又被需要，直接进入 Am
+		if ghost, ok := c.ghostKeys[key]; ok {
+			c.removeGhost(ghost)
+			e.queue = am
+		}
+		c.cache[key] = c.lists[e.queue].PushFront(e)
+		c.resize(e, e.Size(entryOverhead))
+	}
+	for c.maxData != 0 && c.Bytes() > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest：A1in 超出限制或 Am 为空时淘汰 A1in 中最早进入的节点，并将其 key 记录到 A1out；否则淘汰 Am 中最久未访问的节点。
+// 没有任何数据时丢弃 A1out 中最早的 key。幽灵 key 的开销小于数据的开销，因此每次调用都会减少 Bytes
+func (c *Cache) RemoveOldest() {
+	if len(c.cache) == 0 {
+		if ele := c.ghosts.Back(); ele != nil {
+			c.removeGhost(ele)
+		}
+		return
+	}
+	if ele := c.lists[a1in].Back(); ele != nil && (c.inData > c.inMax || c.lists[am].Len() == 0) {
+		c.removeElement(ele)
+		c.addGhost(ele.Value.(*entry).Key)
+		return
+	}
+	if ele := c.lists[am].Back(); ele != nil {
+		c.removeElement(ele)
+	}
+}
+
+// Remove：根据 key 移除对应的节点，key 不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveExpired：移除所有已经过期的节点，返回移除的数量
+func (c *Cache) RemoveExpired() int {
+	return lru.RemoveExpiredElements(c.removeElement, c.lists[:]...)
+}
+
+// Len：获取 Cache 添加了多少条数据，不包括 A1out 中的 key
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes：获取 Cache 当前已使用的内存，包括 A1out 中的 key
+func (c *Cache) Bytes() int64 {
+	return c.nowData + c.ghostData
+}
+
+// SetMaxBytes：修改允许使用的最大内存并按比例调整 A1in 与 A1out 的上限，超出新的上限时立即淘汰
+func (c *Cache) SetMaxBytes(maxData int64) {
+	c.maxData = maxData
+	c.inMax = maxData * inPercent / 100
+	c.ghostMax = maxData * ghostPercent / 100
+	for c.ghosts.Len() > 0 && c.ghostData > c.ghostMax {
+		c.removeGhost(c.ghosts.Back())
+	}
+	for c.maxData != 0 && c.Bytes() > c.maxData {
+		c.RemoveOldest()
+	}
+}
+
+// resize：更新 A1in 及总的内存使用量
+func (c *Cache) resize(e *entry, delta int64) {
+	c.nowData += delta
+	if e.queue == a1in {
+		c.inData += delta
+	}
+}
+
+// removeElement：移除节点并维护映射关系与内存值
+func (c *Cache) removeElement(ele *list.Element) {
+	e := ele.Value.(*entry)
+	c.lists[e.queue].Remove(ele)
+	delete(c.cache, e.Key)
+	c.resize(e, -e.Size(entryOverhead))
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.Key, e.Value)
+	}
+}
+
+// addGhost：将 key 记录到 A1out，超出限制时丢弃最早的 key
+func (c *Cache) addGhost(key string) {
+	c.ghostKeys[key] = c.ghosts.PushFront(key)
+	c.ghostData += int64(len(key)) + ghostOverhead
+	for c.ghostData > c.ghostMax {
+		c.removeGhost(c.ghosts.Back())
+	}
+}
+
+// removeGhost：从 A1out 中移除 key
+func (c *Cache) removeGhost(ele *list.Element) {
+	key := c.ghosts.Remove(ele).(string)
+	delete(c.ghostKeys, key)
+	c.ghostData -= int64(len(key)) + ghostOverhead
+}
@@ -0,0 +1,62 @@
+package twoq
+
+import (
+	"strconv"
+	"testing"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+// TestCache_Ghost：测试被淘汰后再次添加的数据直接进入 Am
+func TestCache_Ghost(t *testing.T) {
+	c := New(10*(10+entryOverhead), nil)
+	c.Add("key1", String("v1"))
+	c.RemoveOldest()
+	if _, ok := c.ghostKeys["key1"]; !ok {
+		t.Fatalf("key1 should be recorded in A1out")
+	}
+	// 幽灵 key 同样占用内存
+	if c.Bytes() != int64(len("key1"))+ghostOverhead {
+		t.Fatalf("ghost key should be counted in Bytes, but %d got", c.Bytes())
+	}
+	c.Add("key1", String("v1"))
+	if e := c.cache["key1"].Value.(*entry); e.queue != am {
+		t.Fatalf("key1 should be promoted to Am")
+	}
+	if _, ok := c.ghostKeys["key1"]; ok || c.ghostData != 0 {
+		t.Fatalf("key1 should be removed from A1out")
+	}
+}
+
+// TestCache_ScanResistance：测试一次性扫描无法挤出 Am 中的热点数据
+func TestCache_ScanResistance(t *testing.T) {
+	c := New(100*(10+entryOverhead), nil)
+	// 每条数据占用 10 字节及额外开销，缓存最多容纳 100 条
+	hot := make([]string, 20)
+	for i := range hot {
+		hot[i] = "hot" + strconv.Itoa(100 + i)[1:] + "xx"
+		c.Add(hot[i], String("vvv"))
+	}
+	// 热点数据被淘汰后不久再次被添加，进入 Am
+	for range hot {
+		c.RemoveOldest()
+	}
+	for _, key := range hot {
+		c.Add(key, String("vvv"))
+	}
+	for i := 0; i < 1000; i++ {
+		key := "scan" + strconv.Itoa(1000 + i)[1:]
+		if _, ok := c.Get(key); !ok {
+			c.Add(key, String("v"))
+		}
+	}
+	for _, key := range hot {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("hot key %s should survive the scan", key)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package carrotcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/Dongxiem/carrotCache/carrotcache/byteview"
+	"github.com/Dongxiem/carrotCache/carrotcache/codec"
+	"github.com/Dongxiem/carrotCache/carrotcache/lru"
+)
+
+// 带类型的 Group：缓存与节点间传输的仍然是 ByteView，TypedGroup 在读写时通过 codec 转换为类型 T。
+// 开启解码缓存后，解码得到的对象按 key 保存在本进程中，只要缓存中的字节没有变化就直接复用，避免重复反序列化。
+
+// TypedGroup：在 Group 之上按照 codec 编解码缓存值，调用方直接读写类型 T
+type TypedGroup[T any] struct {
+	group *Group
+	codec codec.Codec[T]
+
+	mu      sync.Mutex // 保护 decoded
+	decoded *lru.Cache // 解码缓存，为 nil 表示未开启
+}
+
+// TypedOption：NewTypedGroup 的可选配置项
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	decodedBytes int64
+}
+
+// WithDecodedCache：开启解码缓存，最多保存编码后共 maxBytes 字节的对象，小于等于 0 时不开启。
+// 同一个对象会返回给多个调用方，调用方不能修改解码得到的对象
+func WithDecodedCache(maxBytes int64) TypedOption {
+	return func(o *typedOptions) {
+		o.decodedBytes = maxBytes
+	}
+}
+
+// decodedValue：解码缓存中的一项，raw 为解码时使用的字节，与缓存中的字节相同时 value 才可以复用
+type decodedValue[T any] struct {
+	raw   []byte
+	value T
+}
+
+// Len：实现 lru.Value 接口，按照编码后的大小及 decodedValue 结构体本身计算，T 内部引用的内存无法统计
+func (v *decodedValue[T]) Len() int {
+	return len(v.raw) + int(unsafe.Sizeof(*v))
+}
+
+// NewTypedGroup：使用 c 对 g 中的缓存值进行编解码，opts 为可选配置项
+func NewTypedGroup[T any](g *Group, c codec.Codec[T], opts ...TypedOption) *TypedGroup[T] {
+	var o typedOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t := &TypedGroup[T]{group: g, codec: c}
+	if o.decodedBytes > 0 {
+		t.decoded = lru.New(o.decodedBytes, nil)
+	}
+	return t
+}
+
+// NewTypedGetter：将返回类型 T 的回调函数包装为 ContextGetter，源数据使用 c 编码后写入缓存
+func NewTypedGetter[T any](c codec.Codec[T], fn func(ctx context.Context, key string) (T, error)) ContextGetter {
+	return ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		v, err := fn(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return c.Marshal(v)
+	})
+}
+
+// Group：返回底层的 Group
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get：获取 key 对应的对象，详见 GetContext
+func (t *TypedGroup[T]) Get(key string) (T, error) {
+	return t.GetContext(context.Background(), key)
+}
+
+// GetContext：通过 Group.GetContext 获取缓存值并解码
+func (t *TypedGroup[T]) GetContext(ctx context.Context, key string) (T, error) {
+	view, err := t.group.GetContext(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.decode(key, view)
+}
+
+// GetMulti：批量获取对象，详见 GetMultiContext
+func (t *TypedGroup[T]) GetMulti(keys []string) (map[string]T, map[string]error) {
+	return t.GetMultiContext(context.Background(), keys)
+}
+
+// GetMultiContext：通过 Group.GetMultiContext 批量获取缓存值并解码，解码失败的 key 记录在 errs 中
+func (t *TypedGroup[T]) GetMultiContext(ctx context.Context, keys []string) (map[string]T, map[string]error) {
+	views, errs := t.group.GetMultiContext(ctx, keys)
+	values := make(map[string]T, len(views))
+	for key, view := range views {
+		v, err := t.decode(key, view)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		values[key] = v
+	}
+	return values, errs
+}
+
+// Set：将 v 编码后写入缓存，详见 Group.Set
+func (t *TypedGroup[T]) Set(key string, v T, opts SetOptions) error {
+	return t.SetContext(context.Background(), key, v, opts)
+}
+
+// SetContext：将 v 编码后写入缓存，详见 Group.SetContext
+func (t *TypedGroup[T]) SetContext(ctx context.Context, key string, v T, opts SetOptions) error {
+	b, err := t.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %v", key, err)
+	}
+	t.forget(key)
+	return t.group.SetContext(ctx, key, b, opts)
+}
+
+// Remove：删除 key 对应的缓存，详见 Group.Remove
+func (t *TypedGroup[T]) Remove(key string) error {
+	return t.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext：删除 key 对应的缓存及解码缓存，详见 Group.RemoveContext
+func (t *TypedGroup[T]) RemoveContext(ctx context.Context, key string) error {
+	t.forget(key)
+	return t.group.RemoveContext(ctx, key)
+}
+
+// decode：解码缓存值，开启解码缓存时优先复用字节相同的对象
+func (t *TypedGroup[T]) decode(key string, view byteview.ByteView) (T, error) {
+	if t.decoded == nil {
+		return t.unmarshal(key, view.B)
+	}
+	t.mu.Lock()
+	if cached, ok := t.decoded.Get(key); ok {
+		// 缓存中的字节可能已经被重新加载或写入，逐字节比较的代价远小于反序列化
+		if d := cached.(*decodedValue[T]); bytes.Equal(d.raw, view.B) {
+			t.mu.Unlock()
+			return d.value, nil
+		}
+	}
+	t.mu.Unlock()
+
+	v, err := t.unmarshal(key, view.B)
+	if err != nil {
+		return v, err
+	}
+	// 缓存中的字节不会被修改，可以直接引用
+	t.mu.Lock()
+	t.decoded.Add(key, &decodedValue[T]{raw: view.B, value: v})
+	t.mu.Unlock()
+	return v, nil
+}
+
+// unmarshal：使用 codec 解码，失败时在错误中注明 key
+func (t *TypedGroup[T]) unmarshal(key string, b []byte) (T, error) {
+	v, err := t.codec.Unmarshal(b)
+	if err != nil {
+		return v, fmt.Errorf("decoding %s: %v", key, err)
+	}
+	return v, nil
+}
+
+// forget：移除解码缓存中 key 对应的对象
+func (t *TypedGroup[T]) forget(key string) {
+	if t.decoded == nil {
+		return
+	}
+	t.mu.Lock()
+	t.decoded.Remove(key)
+	t.mu.Unlock()
+}
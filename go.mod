@@ -1,10 +1,18 @@
 module github.com/Dongxiem/carrotCache
 
-go 1.15
+go 1.18
 
 require (
-	github.com/golang/protobuf v1.4.2
-	google.golang.org/protobuf v1.23.0
+	github.com/golang/protobuf v1.4.3
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.25.0
+)
+
+require (
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )
 
 replace carrotCache => ./carrotCache
@@ -2,14 +2,20 @@ package main
 
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/Dongxiem/carrotCache/carrotcache"
 	h "github.com/Dongxiem/carrotCache/carrotcache/http"
+	"github.com/Dongxiem/carrotCache/carrotcache/logger"
 	"log"
 	"net/http"
+	"time"
 )
 
+// 示例中输出所有级别的日志，方便观察缓存命中及节点选择的过程
+var lg = logger.New(nil, logger.DebugLevel)
+
 var db = map[string]string{
 	"Tom":  "630",
 	"Jack": "589",
@@ -28,21 +34,25 @@ func createGroup() *carrotcache.Group {
 			if v, ok := db[key]; ok {
 				return []byte(v), nil
 			}
-			// 如果找不到则返回 nil 及错误
-			return nil, fmt.Errorf("%s not exist", key)
-		}))
+			// 如果找不到则返回 nil 及 ErrNotFound，短时间内再次请求该 key 不会访问 db
+			return nil, fmt.Errorf("%s not exist: %w", key, carrotcache.ErrNotFound)
+		}), carrotcache.WithLogger(lg), carrotcache.WithNegativeCache(10*time.Second, 0))
 }
 
 // startCacheServer： 开启 Cache 服务
 func startCacheServer(addr string, addrs []string, cache *carrotcache.Group) {
 	// 根据传递进来的地址 addr 创建一个新的 HTTP 池
-	peers := h.NewHTTPPool(addr)
+	peers := h.NewHTTPPool(addr, h.WithLogger(lg))
 	// 对 peers 添加地址，该 addrs 是一串地址，为字符串切片
 	peers.Set(addrs...)
 	// 并且在 cache 中进行 peers 的注册
 	cache.RegisterPeers(peers)
+	// 节点间通信与 /metrics 指标导出共用同一个端口
+	mux := http.NewServeMux()
+	mux.Handle("/carrotCache/", peers)
+	mux.Handle("/metrics", peers.MetricsHandler())
 	log.Println("carrotCache is running at", addr)
-	log.Fatal(http.ListenAndServe(addr[7:], peers))
+	log.Fatal(http.ListenAndServe(addr[7:], mux))
 }
 
 // startAPIServer： 开启 API 服务
@@ -52,8 +62,13 @@ func startAPIServer(apiAddr string, cache *carrotcache.Group) {
 		func(w http.ResponseWriter, r *http.Request) {
 			// 通过 URL 的 Query() 方法去得到 "key" 键所对应的具体键值
 			key := r.URL.Query().Get("key")
-			// 然后去 cache 当中得到对应的 value
-			view, err := cache.Get(key)
+			// 然后去 cache 当中得到对应的 value，客户端断开连接时放弃等待
+			view, err := cache.GetContext(r.Context(), key)
+			// key 不存在时返回 404
+			if errors.Is(err, carrotcache.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
 			// 此时发生 err 则对应的是：内部服务器（HTTP-Internal Server Error）错误
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)